@@ -95,6 +95,38 @@ func (errs Errors) WithMalformedJSON(message string) Errors {
 	})
 }
 
+func (errs Errors) WithUnsupportedMediaType(message string) Errors {
+	return append(errs, Error{
+		Type:    "request_error",
+		Code:    "unsupported_media_type",
+		Message: message,
+	})
+}
+
+func (errs Errors) WithRequestTooLarge(message string) Errors {
+	return append(errs, Error{
+		Type:    "request_error",
+		Code:    "request_too_large",
+		Message: message,
+	})
+}
+
+func (errs Errors) WithNotAcceptable(message string) Errors {
+	return append(errs, Error{
+		Type:    "request_error",
+		Code:    "not_acceptable",
+		Message: message,
+	})
+}
+
+func (errs Errors) WithPreconditionFailed(message string) Errors {
+	return append(errs, Error{
+		Type:    "request_error",
+		Code:    "precondition_failed",
+		Message: message,
+	})
+}
+
 func (errs Errors) WithRequired(param, message string) Errors {
 	return append(errs, Error{
 		Type:    "validation_error",
@@ -140,6 +172,15 @@ func (errs Errors) WithNotInteger(param, message string) Errors {
 	})
 }
 
+func (errs Errors) WithNotUUID(param, message string) Errors {
+	return append(errs, Error{
+		Type:    "validation_error",
+		Code:    "not_uuid",
+		Message: message,
+		Param:   param,
+	})
+}
+
 func (errs Errors) WithNotList(param, message string) Errors {
 	return append(errs, Error{
 		Type:    "validation_error",
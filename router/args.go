@@ -0,0 +1,81 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+// Int64 return path argument with given name parsed as integer. If value is
+// not a valid integer, validation error for the argument is returned. Like
+// other typed accessors, it sets error Param to the argument name, so that
+// errors can be send to the client as they are:
+//
+//	id, errs := router.Int64(ctx, "id")
+//	if errs != nil {
+//		web.JSONErr(w, errs, http.StatusBadRequest)
+//		return
+//	}
+func Int64(ctx context.Context, name string) (int64, apierr.Errors) {
+	n, err := strconv.ParseInt(Args(ctx).ByName(name), 10, 64)
+	if err != nil {
+		return 0, apierr.Errors{}.WithNotInteger(name, fmt.Sprintf("%q has to be integer", name))
+	}
+	return n, nil
+}
+
+// UUID return path argument with given name, normalized to lower case, if it's
+// valid UUID. Otherwise validation error for the argument is returned.
+func UUID(ctx context.Context, name string) (string, apierr.Errors) {
+	s := strings.ToLower(Args(ctx).ByName(name))
+	if !isUUID(s) {
+		return "", apierr.Errors{}.WithNotUUID(name, fmt.Sprintf("%q has to be UUID", name))
+	}
+	return s, nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Time return path argument with given name parsed using given layout. If value
+// cannot be parsed, validation error for the argument is returned.
+func Time(ctx context.Context, name, layout string) (time.Time, apierr.Errors) {
+	t, err := time.Parse(layout, Args(ctx).ByName(name))
+	if err != nil {
+		return t, apierr.Errors{}.WithNotDatetime(name, fmt.Sprintf("%q has to be datetime in %q format", name, layout))
+	}
+	return t, nil
+}
+
+// Enum return path argument with given name if it's one of allowed choices.
+// Otherwise validation error for the argument is returned.
+func Enum(ctx context.Context, name string, choices ...string) (string, apierr.Errors) {
+	s := Args(ctx).ByName(name)
+	for _, c := range choices {
+		if s == c {
+			return s, nil
+		}
+	}
+	return "", apierr.Errors{}.WithInvalidChoice(name,
+		fmt.Sprintf("%q has to be one of %s", name, strings.Join(choices, ", ")))
+}
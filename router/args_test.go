@@ -0,0 +1,59 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+func TestTypedArgs(t *testing.T) {
+	ctx := WithArgs(context.Background(),
+		"id", "42",
+		"name", "bob",
+		"uuid", "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11",
+		"date", "2016-01-02",
+		"currency", "EUR",
+	)
+
+	if n, errs := Int64(ctx, "id"); errs != nil || n != 42 {
+		t.Errorf("want 42, got %d: %v", n, errs)
+	}
+	if _, errs := Int64(ctx, "name"); !hasError(errs, "not_integer", "name") {
+		t.Errorf("want not_integer error, got %v", errs)
+	}
+
+	if s, errs := UUID(ctx, "uuid"); errs != nil || s != "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11" {
+		t.Errorf("want UUID, got %q: %v", s, errs)
+	}
+	if _, errs := UUID(ctx, "id"); !hasError(errs, "not_uuid", "id") {
+		t.Errorf("want not_uuid error, got %v", errs)
+	}
+
+	want := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	if tm, errs := Time(ctx, "date", "2006-01-02"); errs != nil || !tm.Equal(want) {
+		t.Errorf("want %s, got %s: %v", want, tm, errs)
+	}
+	if _, errs := Time(ctx, "name", time.RFC3339); !hasError(errs, "not_datetime", "name") {
+		t.Errorf("want not_datetime error, got %v", errs)
+	}
+
+	if s, errs := Enum(ctx, "currency", "EUR", "USD"); errs != nil || s != "EUR" {
+		t.Errorf("want EUR, got %q: %v", s, errs)
+	}
+	if _, errs := Enum(ctx, "name", "EUR", "USD"); !hasError(errs, "invalid_choice", "name") {
+		t.Errorf("want invalid_choice error, got %v", errs)
+	}
+}
+
+func hasError(errs apierr.Errors, code, param string) bool {
+	if len(errs) != 1 {
+		return false
+	}
+	want := apierr.Error{Type: "validation_error", Code: code, Param: param}
+	got := errs[0]
+	got.Message = ""
+	return reflect.DeepEqual(want, got)
+}
@@ -0,0 +1,133 @@
+package router
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// conditions defines request attributes other than method and path, that
+// route requires to match.
+type conditions struct {
+	host    *regexp.Regexp
+	headers map[string]string
+}
+
+func newConditions(r route) (*conditions, []string, error) {
+	if r.host == "" && len(r.headers) == 0 {
+		return nil, nil, nil
+	}
+	c := &conditions{}
+	var names []string
+	if r.host != "" {
+		var err error
+		c.host, names, err = compileHost(r.host)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(r.headers) != 0 {
+		c.headers = make(map[string]string, len(r.headers))
+		for name, value := range r.headers {
+			c.headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	return c, names, nil
+}
+
+// compileHost return regular expression build from given host pattern and
+// list of argument names used in it. Unlike path pattern, text outside of
+// argument definitions is matched literally and {name} is matching single
+// domain label.
+func compileHost(pattern string) (*regexp.Regexp, []string, error) {
+	var names []string
+	var raw string
+	var end int
+	for _, loc := range placeholder.FindAllStringIndex(pattern, -1) {
+		raw += regexp.QuoteMeta(pattern[end:loc[0]])
+		end = loc[1]
+
+		chunks := strings.SplitN(pattern[loc[0]+1:loc[1]-1], ":", 2)
+		names = append(names, chunks[0])
+		if len(chunks) == 1 {
+			raw += `([^.]+)`
+		} else {
+			raw += `(` + chunks[1] + `)`
+		}
+	}
+	raw += regexp.QuoteMeta(pattern[end:])
+	rx, err := regexp.Compile(`^(?i)` + raw + `$`)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rx, names, nil
+}
+
+// match return true if given request fulfills all conditions.
+func (c *conditions) match(r *http.Request) bool {
+	if c.host != nil && !c.host.MatchString(requestHost(r)) {
+		return false
+	}
+	for name, value := range c.headers {
+		switch name {
+		case "Accept":
+			if !accepts(r.Header.Get(name), value) {
+				return false
+			}
+		case "Content-Type":
+			mediatype, _, err := mime.ParseMediaType(r.Header.Get(name))
+			if err != nil || !strings.EqualFold(mediatype, value) {
+				return false
+			}
+		default:
+			if r.Header.Get(name) != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hostValues return values of host arguments.
+func (c *conditions) hostValues(r *http.Request) []string {
+	if c.host == nil {
+		return nil
+	}
+	match := c.host.FindStringSubmatch(requestHost(r))
+	if match == nil {
+		return nil
+	}
+	return match[1:]
+}
+
+// requestHost return host the request was send to, without port.
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+// accepts return true if given media type is explicitly acceptable according
+// to Accept header value. Because route declared first takes precedence,
+// missing header and */* are not considered to accept any media type, so that
+// they cannot be dispatched to route that requires specific one.
+func accepts(header, mediatype string) bool {
+	typ := strings.SplitN(mediatype, "/", 2)[0]
+	for _, chunk := range strings.Split(header, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(chunk))
+		if err != nil {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		if strings.EqualFold(rng, mediatype) || strings.EqualFold(rng, typ+"/*") {
+			return true
+		}
+	}
+	return false
+}
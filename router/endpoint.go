@@ -0,0 +1,91 @@
+package router
+
+import "strings"
+
+// Endpoint describes single method and path pattern combination served by
+// the router.
+type Endpoint struct {
+	Method  string
+	Pattern string
+	// Methods is the list of methods accepted by the endpoint. It contains
+	// all standard methods if Method is AnyMethod, or only Method otherwise.
+	Methods []string
+	// Template is the path pattern with custom argument regular expressions
+	// removed, like /users/{id}, or empty string if pattern is using regular
+	// expression outside of argument definitions. For mounts it describes
+	// only the prefix.
+	Template string
+	// Mount is true if endpoint is serving all paths starting with given
+	// prefix, as created with Mount or Files function.
+	Mount bool
+	// Name is the name of the route, if defined.
+	Name string
+	// Host and Headers are the conditions that request must fulfill, if
+	// defined.
+	Host    string
+	Headers map[string]string
+	// Args contains path arguments in order they are declared in pattern.
+	Args []Argument
+	// Query contains declared query string parameters.
+	Query []Param
+}
+
+// Argument describes path argument.
+type Argument struct {
+	Name string
+	// Regexp is the regular expression that argument value must match.
+	Regexp string
+}
+
+func newEndpoints(r route) []Endpoint {
+	var args []Argument
+	for _, s := range placeholder.FindAllString(r.Path, -1) {
+		chunks := strings.SplitN(s[1:len(s)-1], ":", 2)
+		arg := Argument{Name: chunks[0], Regexp: `[^/]+`}
+		if len(chunks) == 2 {
+			arg.Regexp = chunks[1]
+		}
+		args = append(args, arg)
+	}
+
+	mount := strings.HasSuffix(r.Path, mountPattern)
+	var tmpl string
+	if t, err := newTemplate(strings.TrimSuffix(r.Path, mountPattern)); err == nil {
+		tmpl = t.String()
+	}
+
+	var endpoints []Endpoint
+	for _, method := range strings.Split(r.Methods, ",") {
+		methods := []string{method}
+		if method == AnyMethod {
+			methods = standardMethods
+		}
+		endpoints = append(endpoints, Endpoint{
+			Method:   method,
+			Pattern:  r.Path,
+			Methods:  methods,
+			Template: tmpl,
+			Mount:    mount,
+			Name:     r.name,
+			Host:     r.host,
+			Headers:  r.headers,
+			Args:     args,
+			Query:    r.query,
+		})
+	}
+	return endpoints
+}
+
+// Routes return list of all endpoints served by the router, in order they
+// were declared. Routes accepting more than one method are represented by
+// separate endpoint for every method.
+func (rt *Router) Routes() []Endpoint {
+	endpoints := make([]Endpoint, len(rt.endpoints))
+	for i, e := range rt.endpoints {
+		e.Methods = append([]string(nil), e.Methods...)
+		e.Args = append([]Argument(nil), e.Args...)
+		e.Query = append([]Param(nil), e.Query...)
+		endpoints[i] = e
+	}
+	return endpoints
+}
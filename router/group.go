@@ -0,0 +1,42 @@
+package router
+
+// Group return route that is a collection of routes sharing the same path
+// prefix and middlewares. Path of every grouped route is prefixed with given
+// prefix, so arguments declared in the prefix are available to the handler
+// like any other path argument. Groups can be nested.
+//
+// Group middlewares are executed after router middlewares, but before
+// middlewares of the grouped route.
+//
+//	router.Group(`/v1/accounts/{id:\d+}`, router.Routes{
+//	    {Methods: "GET", Path: `/transactions`, Func: HandleListTransactions},
+//	    {Methods: "GET", Path: `/balance`, Func: HandleBalance},
+//	}, RequireAccountOwner)
+func Group(prefix string, routes Routes, middlewares ...Middleware) Route {
+	c := &routeConfig{group: routes, isGroup: true}
+	c.middleware = middlewares
+	return Route{Path: prefix, Func: c.serve}
+}
+
+// flatten return list of routes with their settings resolved and all groups
+// replaced by routes they contain. Declaration order is preserved.
+func flatten(routes Routes) []route {
+	var flat []route
+	for _, r := range routes {
+		c := configOf(r)
+		switch {
+		case c == nil:
+			flat = append(flat, route{Route: r})
+		case !c.isGroup:
+			r.Func = c.fn
+			flat = append(flat, route{Route: r, settings: c.settings})
+		default:
+			for _, child := range flatten(c.group) {
+				child.Path = r.Path + child.Path
+				child.middleware = append(append([]Middleware{}, c.middleware...), child.middleware...)
+				flat = append(flat, child)
+			}
+		}
+	}
+	return flat
+}
@@ -0,0 +1,267 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Metrics collects number of requests, their latency and response size for
+// every route. Metrics are labeled with route pattern instead of request path,
+// to keep number of label values bounded. Requests that were not matched by
+// any route are labeled with "unmatched" route and requests with non standard
+// methods are labeled with "OTHER" method.
+//
+// Use Middleware method as router middleware to collect metrics and Metrics
+// as HTTP handler to expose them using Prometheus text format:
+//
+//	metrics := router.NewMetrics()
+//	rt := router.New(routes, router.Use(metrics.Middleware))
+//	http.Handle("/metrics", metrics)
+type Metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]int64
+	duration map[routeKey]*histogram
+	size     map[routeKey]*histogram
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type requestKey struct {
+	routeKey
+	code int
+}
+
+var (
+	durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	sizeBuckets     = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+// NewMetrics create and return empty metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: make(map[requestKey]int64),
+		duration: make(map[routeKey]*histogram),
+		size:     make(map[routeKey]*histogram),
+		now:      time.Now,
+	}
+}
+
+// Middleware wraps handler function to collect request metrics.
+func (m *Metrics) Middleware(fn HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		start := m.now()
+		sw := &statusWriter{ResponseWriter: w}
+		fn(ctx, sw, r)
+		m.observe(r.Method, Pattern(ctx), sw.status(), sw.size, m.now().Sub(start))
+	}
+}
+
+func (m *Metrics) observe(method, route string, code int, size int64, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	if !isStandardMethod(method) {
+		method = "OTHER"
+	}
+	key := routeKey{method: method, route: route}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{routeKey: key, code: code}]++
+	if m.duration[key] == nil {
+		m.duration[key] = newHistogram(durationBuckets)
+		m.size[key] = newHistogram(sizeBuckets)
+	}
+	m.duration[key].observe(duration.Seconds())
+	m.size[key].observe(float64(size))
+}
+
+func isStandardMethod(method string) bool {
+	for _, m := range standardMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP write all collected metrics using Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	m.mu.Lock()
+	m.writeTo(&b)
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = b.WriteTo(w)
+}
+
+func (m *Metrics) writeTo(b *bytes.Buffer) {
+	reqKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Slice(reqKeys, func(i, j int) bool {
+		if reqKeys[i].routeKey != reqKeys[j].routeKey {
+			return reqKeys[i].routeKey.less(reqKeys[j].routeKey)
+		}
+		return reqKeys[i].code < reqKeys[j].code
+	})
+	b.WriteString("# HELP http_requests_total Total number of HTTP requests.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, k := range reqKeys {
+		fmt.Fprintf(b, "http_requests_total{%s,code=\"%d\"} %d\n", k.labels(), k.code, m.requests[k])
+	}
+
+	writeHistograms(b, "http_request_duration_seconds", "HTTP request latency in seconds.", m.duration)
+	writeHistograms(b, "http_response_size_bytes", "HTTP response body size in bytes.", m.size)
+}
+
+func writeHistograms(b *bytes.Buffer, name, help string, hs map[routeKey]*histogram) {
+	keys := make([]routeKey, 0, len(hs))
+	for k := range hs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s histogram\n", name)
+	for _, k := range keys {
+		h := hs[k]
+		labels := k.labels()
+		for i, le := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(le), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func (k routeKey) less(other routeKey) bool {
+	if k.route != other.route {
+		return k.route < other.route
+	}
+	return k.method < other.method
+}
+
+func (k routeKey) labels() string {
+	return fmt.Sprintf(`method="%s",route="%s"`, escapeLabel(k.method), escapeLabel(k.route))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// histogram counts observations in cumulative buckets.
+type histogram struct {
+	buckets []float64
+	counts  []int64
+	count   int64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]int64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// statusWriter records status code and size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+	size int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, if wrapped writer does.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, if wrapped writer does. Hijacked
+// response is counted with 101 status code.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.code == 0 {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap return wrapped writer, so that http.ResponseController can reach
+// its other methods.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// started return true if response header was already sent.
+func (w *statusWriter) started() bool {
+	return w.code != 0
+}
+
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics.now = func() time.Time {
+		now = now.Add(30 * time.Millisecond)
+		return now
+	}
+
+	rt := New(Routes{
+		{Methods: "GET", Path: `/users/{id:\d+}`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		}},
+		{Methods: "POST", Path: `/users`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}},
+	}, Use(metrics.Middleware))
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"POST", "/users"},
+		{"GET", "/missing"},
+		{"FOO", "/missing"},
+		{"BAR", "/users/1"},
+	} {
+		r, err := http.NewRequest(req.method, req.path, nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		rt.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, nil)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("invalid content type: %q", ct)
+	}
+
+	got := w.Body.String()
+	for _, want := range []string{
+		"# TYPE http_requests_total counter\n",
+		`http_requests_total{method="POST",route="/users",code="201"} 1` + "\n",
+		`http_requests_total{method="GET",route="/users/{id:\\d+}",code="200"} 2` + "\n",
+		`http_requests_total{method="GET",route="unmatched",code="404"} 1` + "\n",
+		`http_requests_total{method="OTHER",route="unmatched",code="404"} 1` + "\n",
+		`http_requests_total{method="OTHER",route="unmatched",code="405"} 1` + "\n",
+		"# TYPE http_request_duration_seconds histogram\n",
+		`http_request_duration_seconds_bucket{method="GET",route="/users/{id:\\d+}",le="0.025"} 0` + "\n",
+		`http_request_duration_seconds_bucket{method="GET",route="/users/{id:\\d+}",le="0.05"} 2` + "\n",
+		`http_request_duration_seconds_bucket{method="GET",route="/users/{id:\\d+}",le="+Inf"} 2` + "\n",
+		`http_request_duration_seconds_sum{method="GET",route="/users/{id:\\d+}"} 0.06` + "\n",
+		`http_request_duration_seconds_count{method="GET",route="/users/{id:\\d+}"} 2` + "\n",
+		`http_response_size_bytes_bucket{method="GET",route="/users/{id:\\d+}",le="100"} 2` + "\n",
+		`http_response_size_bytes_sum{method="GET",route="/users/{id:\\d+}"} 10` + "\n",
+		`http_response_size_bytes_sum{method="POST",route="/users"} 0` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestMetricsHijack(t *testing.T) {
+	metrics := NewMetrics()
+	done := make(chan struct{})
+	rt := New(Routes{
+		{Methods: "GET", Path: `/ws`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			h, ok := w.(http.Hijacker)
+			if !ok {
+				t.Errorf("want http.Hijacker, got %T", w)
+				return
+			}
+			conn, rw, err := h.Hijack()
+			if err != nil {
+				t.Errorf("cannot hijack: %s", err)
+				return
+			}
+			defer conn.Close()
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+			_ = rw.Flush()
+		}},
+	}, Use(func(fn HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fn(ctx, w, r)
+			close(done)
+		}
+	}, metrics.Middleware))
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatalf("cannot get: %s", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("want %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	<-done
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, &http.Request{})
+	if want := `http_requests_total{method="GET",route="/ws",code="101"} 1`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("want %q in metrics, got %q", want, w.Body.String())
+	}
+}
@@ -0,0 +1,36 @@
+package router
+
+import "golang.org/x/net/context"
+
+// Middleware wraps handler function with additional functionality, like
+// authentication, logging or panic recovery.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Use return option setting middlewares that are wrapping all handlers of the
+// router, including not found and method not allowed handlers.
+//
+// Router middlewares are executed first, followed by middlewares defined for
+// the route. Middlewares from the same list are executed in order they are
+// declared.
+func Use(middlewares ...Middleware) Option {
+	return func(rt *Router) {
+		rt.middlewares = append(rt.middlewares, middlewares...)
+	}
+}
+
+// chain return handler wrapped with given middlewares, so that the first
+// middleware is executed first.
+func chain(fn HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+	return fn
+}
+
+// Pattern return path pattern of the route that is serving request. For
+// routes of mounted router, pattern is prefixed with mount prefix. Returns
+// empty string if no route was matched.
+func Pattern(ctx context.Context) string {
+	pattern, _ := ctx.Value("router:pattern").(string)
+	return pattern
+}
@@ -0,0 +1,133 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/optiopay/x/web"
+	"golang.org/x/net/context"
+)
+
+// mountArg is the name of the argument matching path following mount prefix.
+const mountArg = "*"
+
+// mountPattern is appended to the mount prefix to build route path pattern.
+const mountPattern = "{" + mountArg + ":(?:/.*)?}"
+
+// Mount return route that is serving all requests with path starting with
+// given prefix, using given handler. Prefix is stripped from the request path
+// before calling the handler. Prefix can declare arguments like any other
+// path pattern.
+//
+// Use router's ServeCtxHTTP method to mount another router. Path arguments
+// matched by the mounted router are followed by arguments matched by the
+// router it is mounted in:
+//
+//	router.Mount(`/accounts/{account:\d+}`, accounts.ServeCtxHTTP)
+func Mount(prefix string, fn HandlerFunc) Route {
+	return Route{
+		Methods: AnyMethod,
+		Path:    prefix + mountPattern,
+		Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			a := Args(ctx).(*args)
+			stripped := &args{}
+			var rest string
+			var found bool
+			for i, name := range a.names {
+				// only the first one belongs to this route
+				if name == mountArg && !found {
+					rest, found = a.values[i], true
+					continue
+				}
+				stripped.names = append(stripped.names, name)
+				stripped.values = append(stripped.values, a.values[i])
+			}
+
+			mounted, _ := ctx.Value("router:mount").(string)
+			ctx, r = withValue(ctx, r, "router:mount", mounted+prefix)
+			ctx, r = withValue(ctx, r, "router:args", stripped)
+			if strings.HasSuffix(r.URL.Path, rest) {
+				// path matched by the prefix is required to build redirect
+				// location within mounted router
+				p := strings.TrimSuffix(r.URL.Path, rest)
+				mountpath, _ := ctx.Value("router:mountpath").(string)
+				ctx, r = withValue(ctx, r, "router:mountpath", mountpath+(&url.URL{Path: p}).EscapedPath())
+			}
+
+			if rest == "" {
+				rest = "/"
+			}
+
+			u := *r.URL
+			u.Path = rest
+			u.RawPath = ""
+			r.URL = &u
+			fn(ctx, w, r)
+		},
+	}
+}
+
+// Files return route serving files from given file system under given path
+// prefix. Directory requests are served with index.html file that directory
+// contains. Responses carry ETag header and conditional and range requests
+// are supported.
+func Files(prefix string, fs http.FileSystem) Route {
+	r := Mount(prefix, serveFiles(fs))
+	r.Methods = "GET"
+	return r
+}
+
+func serveFiles(fs http.FileSystem) HandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		f, err := fs.Open(name)
+		if err != nil {
+			fileError(w, r, err)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			fileError(w, r, err)
+			return
+		}
+
+		if info.IsDir() {
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				// relative redirect, because request path was stripped
+				w.Header().Set("Location", path.Base(r.URL.Path)+"/")
+				w.WriteHeader(http.StatusMovedPermanently)
+				return
+			}
+			index, err := fs.Open(path.Join(name, "index.html"))
+			if err != nil {
+				fileError(w, r, err)
+				return
+			}
+			defer index.Close()
+			if info, err = index.Stat(); err != nil {
+				fileError(w, r, err)
+				return
+			}
+			f = index
+		}
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	}
+}
+
+func fileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case os.IsNotExist(err):
+		web.StdErr(w, r, http.StatusNotFound)
+	case os.IsPermission(err):
+		web.StdErr(w, r, http.StatusForbidden)
+	default:
+		web.StdErr(w, r, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMount(t *testing.T) {
+	inner := New(Routes{
+		{Methods: "GET", Path: `/`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "index %s %s", r.URL.Path, Pattern(ctx))
+		}},
+		{Methods: "GET", Path: `/files/{name}`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			a := Args(ctx)
+			fmt.Fprintf(w, "%s %s %s %s", a.ByIndex(0), a.ByIndex(1), a.ByName("account"), Pattern(ctx))
+		}},
+	})
+	rt := New(Routes{
+		Mount(`/accounts/{account:\d+}`, inner.ServeCtxHTTP),
+		{Methods: "GET", Path: `/accounts`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "accounts")
+		}},
+	})
+
+	var testCases = []struct {
+		method   string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"GET", "/accounts", http.StatusOK, "accounts"},
+		{"GET", "/accounts/12", http.StatusOK, `index / /accounts/{account:\d+}/`},
+		{"GET", "/accounts/12/", http.StatusOK, `index / /accounts/{account:\d+}/`},
+		{"GET", "/accounts/12/files/a.txt", http.StatusOK, `a.txt 12 12 /accounts/{account:\d+}/files/{name}`},
+		{"GET", "/accounts/12/missing", http.StatusNotFound, ""},
+		{"POST", "/accounts/12/files/a.txt", http.StatusMethodNotAllowed, ""},
+		{"GET", "/accounts/xy/files/a.txt", http.StatusNotFound, ""},
+		{"GET", "/accounts12", http.StatusNotFound, ""},
+	}
+
+	for i, tc := range testCases {
+		r, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if tc.wantCode == http.StatusOK && w.Body.String() != tc.wantBody {
+			t.Errorf("%d: want body %q, got %q", i, tc.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "router")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"index.html":     "<h1>index</h1>",
+		"app.js":         "console.log('hello')",
+		"docs/index.txt": "not an index",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("cannot create directory: %s", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("cannot write file: %s", err)
+		}
+	}
+
+	rt := New(Routes{
+		Files(`/static`, http.Dir(dir)),
+	})
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("GET", "/static/app.js", nil)
+	if w.Code != http.StatusOK || w.Body.String() != files["app.js"] {
+		t.Fatalf("want file content, got %d: %q", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag header")
+	}
+
+	if w := serve("GET", "/static/app.js", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("want %d, got %d", http.StatusNotModified, w.Code)
+	}
+	if w := serve("GET", "/static/app.js", map[string]string{"Range": "bytes=0-6"}); w.Code != http.StatusPartialContent || w.Body.String() != "console" {
+		t.Errorf("want partial content, got %d: %q", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/static/", nil); w.Code != http.StatusOK || w.Body.String() != files["index.html"] {
+		t.Errorf("want index content, got %d: %q", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/static/docs", nil); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "docs/" {
+		t.Errorf("want redirect, got %d: %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := serve("GET", "/static/docs/", nil); w.Code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := serve("GET", "/static/../../etc/passwd", nil); w.Code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := serve("HEAD", "/static/app.js", nil); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("want empty response, got %d: %q", w.Code, w.Body.String())
+	}
+	if w := serve("POST", "/static/app.js", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,182 @@
+// Package openapi provides export of router endpoints as OpenAPI 3
+// specification skeleton.
+package openapi
+
+import (
+	"regexp/syntax"
+	"strings"
+
+	"github.com/optiopay/x/router"
+)
+
+// PathItem maps lower case HTTP method to operation description.
+type PathItem map[string]*Operation
+
+// Operation is OpenAPI 3 operation object.
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is OpenAPI 3 parameter object.
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   Schema `json:"schema"`
+}
+
+// Schema is OpenAPI 3 schema object, limited to attributes that can be
+// inferred from regular expression.
+type Schema struct {
+	Type    string   `json:"type"`
+	Pattern string   `json:"pattern,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+}
+
+// Response is OpenAPI 3 response object.
+type Response struct {
+	Description string `json:"description"`
+}
+
+// Paths return OpenAPI 3 paths object describing given endpoints. Every path
+// argument is described as required path parameter, with schema inferred from
+// argument's regular expression. Declared query string parameters are
+// described as well.
+//
+// Endpoints with path patterns using regular expressions outside of argument
+// definition and endpoints serving mounted handlers cannot be described and
+// are skipped. Endpoints accepting any method are described for all standard
+// methods.
+//
+// Returned structure is only a skeleton, that can be JSON serialized and
+// completed by hand.
+func Paths(endpoints []router.Endpoint) map[string]PathItem {
+	names := make(map[string]int)
+	for _, e := range endpoints {
+		if e.Name != "" {
+			names[e.Name]++
+		}
+	}
+
+	paths := make(map[string]PathItem)
+	for _, e := range endpoints {
+		if e.Template == "" || e.Mount {
+			continue
+		}
+		item, ok := paths[e.Template]
+		if !ok {
+			item = make(PathItem)
+			paths[e.Template] = item
+		}
+
+		for _, method := range e.Methods {
+			method = strings.ToLower(method)
+			if _, ok := item[method]; ok {
+				// routes declared first take precedence
+				continue
+			}
+			op := &Operation{
+				OperationID: e.Name,
+				Responses: map[string]Response{
+					"default": {Description: "Default response"},
+				},
+			}
+			if e.Name != "" && (names[e.Name] > 1 || len(e.Methods) > 1) {
+				op.OperationID = e.Name + "-" + method
+			}
+			for _, arg := range e.Args {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:     arg.Name,
+					In:       "path",
+					Required: true,
+					Schema:   inferSchema(arg.Regexp),
+				})
+			}
+			for _, p := range e.Query {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:     p.Name,
+					In:       "query",
+					Required: p.Required,
+					Schema:   Schema{Type: p.Type.String(), Enum: p.Choices},
+				})
+			}
+			item[method] = op
+		}
+	}
+	return paths
+}
+
+// inferSchema return schema describing strings matching given regular
+// expression.
+func inferSchema(rx string) Schema {
+	if rx == `[^/]+` {
+		return Schema{Type: "string"}
+	}
+	fallback := Schema{Type: "string", Pattern: "^(?:" + rx + ")$"}
+
+	re, err := syntax.Parse(rx, syntax.Perl)
+	if err != nil {
+		return fallback
+	}
+	re = re.Simplify()
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+
+	switch {
+	case isInteger(re):
+		return Schema{Type: "integer"}
+	case re.Op == syntax.OpLiteral:
+		return Schema{Type: "string", Enum: []string{string(re.Rune)}}
+	case re.Op == syntax.OpAlternate:
+		var enum []string
+		for _, sub := range re.Sub {
+			if sub.Op != syntax.OpLiteral {
+				return fallback
+			}
+			enum = append(enum, string(sub.Rune))
+		}
+		return Schema{Type: "string", Enum: enum}
+	}
+	return fallback
+}
+
+// isInteger return true if given regular expression is matching only non
+// empty strings of digits, optionally preceded by minus sign.
+func isInteger(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpConcat && len(re.Sub) == 2 && isMinus(re.Sub[0]) {
+		re = re.Sub[1]
+	}
+	switch re.Op {
+	case syntax.OpPlus:
+		return isDigit(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min > 0 && isDigit(re.Sub[0])
+	case syntax.OpConcat:
+		// \d\d* is how simplified \d{1,} looks like
+		for i, sub := range re.Sub {
+			if isDigit(sub) {
+				continue
+			}
+			if i > 0 && (sub.Op == syntax.OpStar || sub.Op == syntax.OpQuest || sub.Op == syntax.OpRepeat) && isDigit(sub.Sub[0]) {
+				continue
+			}
+			return false
+		}
+		return isDigit(re.Sub[0])
+	}
+	return isDigit(re)
+}
+
+func isDigit(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpCharClass && len(re.Rune) == 2 && re.Rune[0] == '0' && re.Rune[1] == '9'
+}
+
+func isMinus(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpQuest {
+		re = re.Sub[0]
+	}
+	return re.Op == syntax.OpLiteral && string(re.Rune) == "-"
+}
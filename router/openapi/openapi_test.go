@@ -0,0 +1,123 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/optiopay/x/router"
+)
+
+func TestPaths(t *testing.T) {
+	rt := router.New(router.Routes{
+		router.With(router.Route{Methods: "GET", Path: `/users`}, router.Name("users"), router.QueryParams(
+			router.Page(),
+			router.Param{Name: "status", Required: true, Choices: []string{"active", "blocked"}},
+		)),
+		router.With(router.Route{Methods: "GET,PUT", Path: `/users/{id:\d+}`}, router.Name("user")),
+		{Methods: "GET", Path: `/users/{id:\d+}/files/{name}.{ext:json|xml}`},
+		{Methods: "DELETE", Path: `/users/{code:[A-Z]+}`},
+		{Methods: router.AnyMethod, Path: `/static/.*`},
+		{Methods: "GET", Path: `/export\.csv`},
+		router.Files(`/static`, http.Dir(".")),
+	})
+
+	b, err := json.Marshal(Paths(rt.Routes()))
+	if err != nil {
+		t.Fatalf("cannot serialize: %s", err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("cannot deserialize: %s", err)
+	}
+
+	var want interface{}
+	if err := json.Unmarshal([]byte(`{
+		"/users": {
+			"get": {
+				"operationId": "users",
+				"parameters": [
+					{"name": "page", "in": "query", "required": false, "schema": {"type": "integer"}},
+					{"name": "status", "in": "query", "required": true, "schema": {"type": "string", "enum": ["active", "blocked"]}}
+				],
+				"responses": {"default": {"description": "Default response"}}
+			}
+		},
+		"/users/{id}": {
+			"get": {
+				"operationId": "user-get",
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}
+				],
+				"responses": {"default": {"description": "Default response"}}
+			},
+			"put": {
+				"operationId": "user-put",
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}
+				],
+				"responses": {"default": {"description": "Default response"}}
+			}
+		},
+		"/users/{id}/files/{name}.{ext}": {
+			"get": {
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}},
+					{"name": "name", "in": "path", "required": true, "schema": {"type": "string"}},
+					{"name": "ext", "in": "path", "required": true, "schema": {"type": "string", "enum": ["json", "xml"]}}
+				],
+				"responses": {"default": {"description": "Default response"}}
+			}
+		},
+		"/export.csv": {
+			"get": {
+				"responses": {"default": {"description": "Default response"}}
+			}
+		},
+		"/users/{code}": {
+			"delete": {
+				"parameters": [
+					{"name": "code", "in": "path", "required": true, "schema": {"type": "string", "pattern": "^(?:[A-Z]+)$"}}
+				],
+				"responses": {"default": {"description": "Default response"}}
+			}
+		}
+	}`), &want); err != nil {
+		t.Fatalf("cannot deserialize: %s", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want\n%s\ngot\n%s", mustJSON(want), mustJSON(got))
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	var testCases = []struct {
+		rx   string
+		want Schema
+	}{
+		{`[^/]+`, Schema{Type: "string"}},
+		{`\d+`, Schema{Type: "integer"}},
+		{`[0-9]+`, Schema{Type: "integer"}},
+		{`-?\d+`, Schema{Type: "integer"}},
+		{`\d{4}`, Schema{Type: "integer"}},
+		{`\d*`, Schema{Type: "string", Pattern: `^(?:\d*)$`}},
+		{`me`, Schema{Type: "string", Enum: []string{"me"}}},
+		{`(?:EUR|USD)`, Schema{Type: "string", Enum: []string{"EUR", "USD"}}},
+		{`[a-z]+`, Schema{Type: "string", Pattern: `^(?:[a-z]+)$`}},
+	}
+	for i, tc := range testCases {
+		if got := inferSchema(tc.rx); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d: %q: want %#v, got %#v", i, tc.rx, tc.want, got)
+		}
+	}
+}
+
+func mustJSON(v interface{}) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,154 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// SlashPolicy defines how router handles request path that is not matching
+// any route, but would if trailing slash was added or removed.
+type SlashPolicy int
+
+const (
+	// SlashStrict is the default policy, that treats paths with and without
+	// trailing slash as different paths.
+	SlashStrict SlashPolicy = iota
+	// SlashMatch serves request with the route matching path with trailing
+	// slash added or removed.
+	SlashMatch
+	// SlashRedirect redirects request to path with trailing slash added or
+	// removed, if there is a route matching it. Requests to not clean paths
+	// are redirected to clean path as well. GET and HEAD requests are
+	// redirected with 301, all other with 308 status code, so that method
+	// and body are preserved.
+	SlashRedirect
+)
+
+// TrailingSlash return option setting trailing slash policy of the router.
+//
+// No matter of the policy, request path is cleaned before matching, so that
+// multiple slashes, . and .. elements are not matched against routes. Only
+// with SlashRedirect policy client is redirected to clean path, otherwise
+// handler is called with request carrying clean path.
+func TrailingSlash(policy SlashPolicy) Option {
+	return func(rt *Router) {
+		rt.slash = policy
+	}
+}
+
+// cleanPath return canonical form of the given path, with trailing slash
+// preserved.
+func cleanPath(p string) string {
+	if p == "" {
+		return p
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	clean := path.Clean(p)
+	if p[len(p)-1] == '/' && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+// decodeUnreserved return escaped path with percent-encoded unreserved
+// characters decoded. Encoding them does not change the meaning of the path,
+// but it would hide dot segments, like %2E%2E, from cleanPath.
+func decodeUnreserved(p string) string {
+	if strings.IndexByte(p, '%') == -1 {
+		return p
+	}
+	b := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '%' && i+2 < len(p) {
+			if c, err := strconv.ParseUint(p[i+1:i+3], 16, 8); err == nil && isUnreserved(byte(c)) {
+				b = append(b, byte(c))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, p[i])
+	}
+	return string(b)
+}
+
+// isUnreserved return true if given character is unreserved, as defined by
+// RFC 3986.
+func isUnreserved(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// toggleSlash return given path with trailing slash added or removed. Empty
+// string is returned if there is no alternative path.
+func toggleSlash(p string) string {
+	switch {
+	case p == "" || p == "/":
+		return ""
+	case p[len(p)-1] == '/':
+		return p[:len(p)-1]
+	default:
+		return p + "/"
+	}
+}
+
+// withPath return shallow copy of the request with URL path replaced. Path
+// must be escaped if request URL has RawPath set.
+func withPath(r *http.Request, p string) *http.Request {
+	u := *r.URL
+	if r.URL.RawPath != "" {
+		u.RawPath = p
+		u.Path = unescape(p)
+	} else {
+		u.Path = p
+	}
+	r = r.WithContext(r.Context())
+	r.URL = &u
+	return r
+}
+
+// redirect client to given path. Path must be escaped if request URL has
+// RawPath set.
+func redirect(ctx context.Context, w http.ResponseWriter, r *http.Request, p string) {
+	location := p
+	if r.URL.RawPath == "" {
+		location = (&url.URL{Path: p}).EscapedPath()
+	}
+	// path of the router this router is mounted in
+	if prefix, ok := ctx.Value("router:mountpath").(string); ok {
+		location = prefix + location
+	}
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	code := http.StatusPermanentRedirect
+	if r.Method == "GET" || r.Method == "HEAD" {
+		code = http.StatusMovedPermanently
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(code)
+}
+
+// unescape return decoded path or path segment. If it cannot be decoded, it
+// is returned as it is.
+func unescape(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			if u, err := url.PathUnescape(s); err == nil {
+				return u
+			}
+			return s
+		}
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, Pattern(ctx))
+	}
+	routes := Routes{
+		{Methods: "GET,POST", Path: `/users`, Func: handler},
+		{Methods: "GET", Path: `/users/{id}/`, Func: handler},
+		{Methods: "GET", Path: `/files/{name}`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, Args(ctx).ByName("name"))
+		}},
+	}
+
+	var testCases = []struct {
+		policy       SlashPolicy
+		method       string
+		path         string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{SlashStrict, "GET", "/users", http.StatusOK, "", "/users /users"},
+		{SlashStrict, "GET", "/users/", http.StatusNotFound, "", ""},
+		{SlashStrict, "GET", "/users/1", http.StatusNotFound, "", ""},
+		{SlashStrict, "GET", "//users/./1/../", http.StatusNotFound, "", ""},
+		{SlashStrict, "GET", "/x/../users", http.StatusOK, "", "/users /users"},
+		{SlashStrict, "GET", "/files/a%2Fb", http.StatusOK, "", "a/b"},
+		{SlashStrict, "GET", "/files/a%20b", http.StatusOK, "", "a b"},
+		{SlashStrict, "GET", "/files/%2E%2E", http.StatusNotFound, "", ""},
+		{SlashStrict, "GET", "/files/.%2e", http.StatusNotFound, "", ""},
+		{SlashStrict, "GET", "/files/%2e", http.StatusNotFound, "", ""},
+		{SlashStrict, "GET", "/files/x/%2E%2E/a%2Fb", http.StatusOK, "", "a/b"},
+		{SlashStrict, "GET", "/files/%61%2Fb", http.StatusOK, "", "a/b"},
+
+		{SlashMatch, "GET", "/users/", http.StatusOK, "", "/users /users"},
+		{SlashMatch, "POST", "/users/", http.StatusOK, "", "/users /users"},
+		{SlashMatch, "GET", "/users/1", http.StatusOK, "", "/users/1/ /users/{id}/"},
+		{SlashMatch, "GET", "//users//1", http.StatusOK, "", "/users/1/ /users/{id}/"},
+		{SlashMatch, "PUT", "/users/", http.StatusMethodNotAllowed, "", ""},
+		{SlashMatch, "GET", "/files/", http.StatusNotFound, "", ""},
+
+		{SlashRedirect, "GET", "/users", http.StatusOK, "", "/users /users"},
+		{SlashRedirect, "GET", "/users/", http.StatusMovedPermanently, "/users", ""},
+		{SlashRedirect, "HEAD", "/users/", http.StatusMovedPermanently, "/users", ""},
+		{SlashRedirect, "POST", "/users/", http.StatusPermanentRedirect, "/users", ""},
+		{SlashRedirect, "GET", "/users/1?a=b", http.StatusMovedPermanently, "/users/1/?a=b", ""},
+		{SlashRedirect, "GET", "/x/../users/1/", http.StatusMovedPermanently, "/users/1/", ""},
+		{SlashRedirect, "GET", "/users//a%20b", http.StatusMovedPermanently, "/users/a%20b/", ""},
+		{SlashRedirect, "GET", "/x/../files/a%2Fb", http.StatusMovedPermanently, "/files/a%2Fb", ""},
+		{SlashRedirect, "GET", "/x/%2E%2E/files/a%2Fb", http.StatusMovedPermanently, "/files/a%2Fb", ""},
+		{SlashRedirect, "GET", "/files/.%2e", http.StatusNotFound, "", ""},
+		{SlashRedirect, "GET", "/x/../missing", http.StatusNotFound, "", ""},
+		{SlashRedirect, "PUT", "/users/", http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for i, tc := range testCases {
+		rt := New(routes, TrailingSlash(tc.policy))
+		r, err := http.NewRequest(tc.method, "http://example.com"+tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != tc.wantLocation {
+			t.Errorf("%d: want location %q, got %q", i, tc.wantLocation, loc)
+		}
+		if tc.wantCode == http.StatusOK && tc.method != "HEAD" && w.Body.String() != tc.wantBody {
+			t.Errorf("%d: want body %q, got %q", i, tc.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestTrailingSlashMounted(t *testing.T) {
+	inner := New(Routes{
+		{Methods: "GET", Path: `/items/`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "items")
+		}},
+	}, TrailingSlash(SlashRedirect))
+	rt := New(Routes{
+		Mount(`/accounts/{account}`, inner.ServeCtxHTTP),
+	})
+
+	r, err := http.NewRequest("GET", "/accounts/a%20b/items?page=2", nil)
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("want %d, got %d", http.StatusMovedPermanently, w.Code)
+	}
+	if want, got := "/accounts/a%20b/items/?page=2", w.Header().Get("Location"); got != want {
+		t.Errorf("want location %q, got %q", want, got)
+	}
+}
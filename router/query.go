@@ -0,0 +1,236 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/web"
+	"golang.org/x/net/context"
+)
+
+// ParamType defines how query parameter value is parsed.
+type ParamType int
+
+const (
+	// StringParam accepts any value. It's the default type.
+	StringParam ParamType = iota
+	// IntParam accepts decimal integer values.
+	IntParam
+	// BoolParam accepts values understood by strconv.ParseBool.
+	BoolParam
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case IntParam:
+		return "integer"
+	case BoolParam:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// Param declares query string parameter expected by the route.
+type Param struct {
+	Name string
+	Type ParamType
+	// Required parameter must be present in every request.
+	Required bool
+	// Default is the value used when parameter is not present. It must be
+	// valid value of declared type.
+	Default string
+	// Min and Max define inclusive bounds of integer parameter. Each bound
+	// is checked only if its HasMin or HasMax flag is set, so that parameter
+	// can have single bound.
+	Min, Max       int64
+	HasMin, HasMax bool
+	// Choices is optional list of allowed string values.
+	Choices []string
+	// Invalid is used to create validation error for invalid value instead of
+	// the one matching failed check, for example:
+	//
+	//	Invalid: apierr.Errors.WithInvalidPage
+	Invalid func(errs apierr.Errors, param, message string) apierr.Errors
+}
+
+// Page return declaration of the page number parameter, starting at 1.
+func Page() Param {
+	return Param{
+		Name:    "page",
+		Type:    IntParam,
+		Default: "1",
+		Min:     1,
+		HasMin:  true,
+		Invalid: apierr.Errors.WithInvalidPage,
+	}
+}
+
+// PageSize return declaration of the page size parameter, with given default
+// and maximum value.
+func PageSize(def, max int64) Param {
+	return Param{
+		Name:    "pageSize",
+		Type:    IntParam,
+		Default: strconv.FormatInt(def, 10),
+		Min:     1,
+		Max:     max,
+		HasMin:  true,
+		HasMax:  true,
+		Invalid: apierr.Errors.WithInvalidPageSize,
+	}
+}
+
+// parse return value of the parameter found in given query, with default
+// applied.
+func (p *Param) parse(query map[string][]string) (interface{}, apierr.Errors) {
+	values := query[p.Name]
+	switch {
+	case len(values) > 1:
+		return nil, apierr.Errors{}.WithNotSingleValue(p.Name, fmt.Sprintf("%q has to be single value", p.Name))
+	case len(values) == 0 && p.Required:
+		return nil, apierr.Errors{}.WithRequired(p.Name, fmt.Sprintf("%q is required", p.Name))
+	case len(values) == 0 && p.Default == "":
+		return nil, nil
+	}
+	raw := p.Default
+	if len(values) == 1 {
+		raw = values[0]
+	}
+
+	invalid := func(check func(apierr.Errors, string, string) apierr.Errors, format string, a ...interface{}) apierr.Errors {
+		if p.Invalid != nil {
+			check = p.Invalid
+		}
+		return check(apierr.Errors{}, p.Name, fmt.Sprintf("%q "+format, append([]interface{}{p.Name}, a...)...))
+	}
+
+	switch p.Type {
+	case IntParam:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, invalid(apierr.Errors.WithNotInteger, "has to be integer")
+		}
+		switch {
+		case p.HasMin && p.HasMax && (n < p.Min || n > p.Max):
+			return nil, invalid(apierr.Errors.WithNotInRange, "has to be between %d and %d", p.Min, p.Max)
+		case p.HasMin && n < p.Min:
+			return nil, invalid(apierr.Errors.WithNotInRange, "has to be at least %d", p.Min)
+		case p.HasMax && n > p.Max:
+			return nil, invalid(apierr.Errors.WithNotInRange, "has to be at most %d", p.Max)
+		}
+		return n, nil
+	case BoolParam:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, invalid(apierr.Errors.WithNotBoolean, "has to be boolean")
+		}
+		return b, nil
+	default:
+		if len(p.Choices) == 0 {
+			return raw, nil
+		}
+		for _, c := range p.Choices {
+			if raw == c {
+				return raw, nil
+			}
+		}
+		return nil, invalid(apierr.Errors.WithInvalidChoice, "has to be one of %s", strings.Join(p.Choices, ", "))
+	}
+}
+
+// QueryValues provides access to validated query parameters declared by the
+// route.
+type QueryValues interface {
+	// Has return true if parameter was given or has default value.
+	Has(name string) bool
+	// String return value of the parameter as string.
+	String(name string) string
+	// Int64 return value of integer parameter or 0.
+	Int64(name string) int64
+	// Bool return value of boolean parameter or false.
+	Bool(name string) bool
+}
+
+type queryValues map[string]interface{}
+
+func (q queryValues) Has(name string) bool {
+	_, ok := q[name]
+	return ok
+}
+
+func (q queryValues) String(name string) string {
+	switch v := q[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (q queryValues) Int64(name string) int64 {
+	n, _ := q[name].(int64)
+	return n
+}
+
+func (q queryValues) Bool(name string) bool {
+	b, _ := q[name].(bool)
+	return b
+}
+
+// Query return query parameters declared by the route serving request and
+// validated by the router. Default values are applied to parameters that
+// were not given.
+func Query(ctx context.Context) QueryValues {
+	q, ok := ctx.Value("router:query").(queryValues)
+	if !ok {
+		return queryValues{}
+	}
+	return q
+}
+
+// checkQuery validate declared parameters, before calling given handler. If
+// any parameter is invalid, 400 response listing all errors is written
+// instead.
+func checkQuery(params []Param, fn HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		values := make(queryValues, len(params))
+		var errs apierr.Errors
+		for i := range params {
+			v, perrs := params[i].parse(query)
+			if perrs != nil {
+				errs = append(errs, perrs...)
+				continue
+			}
+			if v != nil {
+				values[params[i].Name] = v
+			}
+		}
+		if len(errs) != 0 {
+			web.Err(w, r, errs, http.StatusBadRequest)
+			return
+		}
+		ctx, r = withValue(ctx, r, "router:query", values)
+		fn(ctx, w, r)
+	}
+}
+
+// checkParams return error if default value of any parameter is not valid.
+func checkParams(params []Param) error {
+	for _, p := range params {
+		if p.Default == "" {
+			continue
+		}
+		p.Required = false
+		if _, errs := p.parse(nil); errs != nil {
+			return fmt.Errorf("invalid default value of %q: %s", p.Name, errs[0].Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+func TestQuery(t *testing.T) {
+	rt := New(Routes{
+		With(Route{
+			Methods: "GET",
+			Path:    `/users`,
+			Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				q := Query(ctx)
+				fmt.Fprintf(w, "%d %d %q %t %t %d %q",
+					q.Int64("page"), q.Int64("pageSize"), q.String("status"),
+					q.Bool("verified"), q.Has("age"), q.Int64("age"), q.String("q"))
+			},
+		}, QueryParams(
+			Page(),
+			PageSize(20, 100),
+			Param{Name: "status", Choices: []string{"active", "blocked"}},
+			Param{Name: "verified", Type: BoolParam},
+			Param{Name: "age", Type: IntParam, Min: 18, Max: 99, HasMin: true, HasMax: true},
+			Param{Name: "q"},
+		)),
+		With(Route{
+			Methods: "GET",
+			Path:    `/search`,
+			Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, Query(ctx).String("q"))
+			},
+		}, QueryParams(Param{Name: "q", Required: true})),
+		With(Route{
+			Methods: "GET",
+			Path:    `/adults`,
+			Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, Query(ctx).Int64("age"))
+			},
+		}, QueryParams(Param{Name: "age", Type: IntParam, Min: 18, HasMin: true})),
+	})
+
+	var testCases = []struct {
+		path     string
+		wantCode int
+		wantBody string
+		wantErrs apierr.Errors
+	}{
+		{"/users", http.StatusOK, `1 20 "" false false 0 ""`, nil},
+		{"/users?page=3&pageSize=100&status=active&verified=true&age=18&q=bob", http.StatusOK, `3 100 "active" true true 18 "bob"`, nil},
+		{"/users?q=", http.StatusOK, `1 20 "" false false 0 ""`, nil},
+		{"/users?page=0", http.StatusBadRequest, "", apierr.Errors{
+			{Type: "validation_error", Code: "invalid_page", Param: "page"},
+		}},
+		{"/users?page=x&pageSize=101&status=new&verified=maybe&age=17&q=a&q=b", http.StatusBadRequest, "", apierr.Errors{
+			{Type: "validation_error", Code: "invalid_page", Param: "page"},
+			{Type: "validation_error", Code: "invalid_page_size", Param: "pageSize"},
+			{Type: "validation_error", Code: "invalid_choice", Param: "status"},
+			{Type: "validation_error", Code: "not_boolean", Param: "verified"},
+			{Type: "validation_error", Code: "not_in_range", Param: "age"},
+			{Type: "validation_error", Code: "not_single_value", Param: "q"},
+		}},
+		{"/users?age=old", http.StatusBadRequest, "", apierr.Errors{
+			{Type: "validation_error", Code: "not_integer", Param: "age"},
+		}},
+		{"/search?q=bob", http.StatusOK, "bob", nil},
+		{"/search", http.StatusBadRequest, "", apierr.Errors{
+			{Type: "validation_error", Code: "required", Param: "q"},
+		}},
+		{"/adults?age=200", http.StatusOK, "200", nil},
+		{"/adults?age=3", http.StatusBadRequest, "", apierr.Errors{
+			{Type: "validation_error", Code: "not_in_range", Param: "age"},
+		}},
+		{"/users?page=9223372036854775807", http.StatusOK, `9223372036854775807 20 "" false false 0 ""`, nil},
+	}
+
+	for i, tc := range testCases {
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if tc.wantCode == http.StatusOK {
+			if w.Body.String() != tc.wantBody {
+				t.Errorf("%d: want body %q, got %q", i, tc.wantBody, w.Body.String())
+			}
+			continue
+		}
+		var resp struct {
+			Errors apierr.Errors
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%d: cannot decode response: %s", i, err)
+		}
+		for i := range resp.Errors {
+			resp.Errors[i].Message = ""
+		}
+		if !reflect.DeepEqual(resp.Errors, tc.wantErrs) {
+			t.Errorf("%d: want %v, got %v", i, tc.wantErrs, resp.Errors)
+		}
+	}
+}
+
+func TestQueryInvalidDefault(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic")
+		}
+	}()
+	New(Routes{
+		With(Route{Methods: "GET", Path: `/`}, QueryParams(Param{Name: "n", Type: IntParam, Default: "x"})),
+	})
+}
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/web"
+	"golang.org/x/net/context"
+)
+
+// KeyFunc return the key that requests are rate limited by. Requests sharing
+// the same key share the same limit.
+type KeyFunc func(ctx context.Context, r *http.Request) string
+
+// ClientIP is a KeyFunc limiting requests by the client IP address. Only the
+// address of the connection is considered, so behind a proxy use custom key
+// function that trusts forwarding headers set by the proxy.
+func ClientIP(ctx context.Context, r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ContextKey return KeyFunc limiting requests by the value stored in context
+// under given key, like authenticated subject set by authentication
+// middleware. Requests without such value are limited by the client IP.
+func ContextKey(key interface{}) KeyFunc {
+	return func(ctx context.Context, r *http.Request) string {
+		v := ctx.Value(key)
+		if v == nil {
+			return "ip:" + ClientIP(ctx, r)
+		}
+		return fmt.Sprintf("key:%v", v)
+	}
+}
+
+// RateLimiter limits number of requests using token bucket algorithm. Every
+// key has its own bucket, that holds up to burst tokens and is refilled with
+// given rate. Request consumes single token, and when the bucket is empty,
+// request is rejected with 429 response carrying Retry-After header and
+// rate_limit error.
+//
+// State is kept in memory, so every application instance has its own limits.
+// Use Middleware method as route or group middleware. Routes sharing the same
+// limiter share the same limits:
+//
+//	limiter := router.NewRateLimiter(10, time.Minute, router.ClientIP)
+//	router.Group(`/v1/auth`, authRoutes, limiter.Middleware)
+type RateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+	key   KeyFunc
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	cleaned time.Time
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter create rate limiter allowing given number of requests per
+// given period for every key returned by key function. If key function is
+// nil, requests are limited by the client IP.
+func NewRateLimiter(requests int, per time.Duration, key KeyFunc) *RateLimiter {
+	if requests <= 0 || per <= 0 {
+		panic("rate limit must be positive")
+	}
+	if key == nil {
+		key = ClientIP
+	}
+	return &RateLimiter{
+		rate:    float64(requests) / per.Seconds(),
+		burst:   float64(requests),
+		key:     key,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Middleware wraps handler function to reject requests exceeding the limit.
+func (rl *RateLimiter) Middleware(fn HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		wait := rl.take(rl.key(ctx, r))
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			errs := apierr.Errors{}.WithRateLimit("too many requests, retry in " + wait.String())
+			web.Err(w, r, errs, http.StatusTooManyRequests)
+			return
+		}
+		fn(ctx, w, r)
+	}
+}
+
+// take consume single token from the bucket of given key. If bucket is
+// empty, time after which token will be available is returned.
+func (rl *RateLimiter) take(key string) time.Duration {
+	now := rl.now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.clean(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.burst, updated: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = rl.tokens(b, now)
+	b.updated = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// tokens return number of tokens in the bucket at given time.
+func (rl *RateLimiter) tokens(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.updated).Seconds()*rl.rate
+	if tokens > rl.burst {
+		return rl.burst
+	}
+	return tokens
+}
+
+// clean remove buckets that are full, because they are the same as new ones.
+// To keep the cost low, it's done at most once per time needed to refill the
+// whole bucket.
+func (rl *RateLimiter) clean(now time.Time) {
+	refill := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	if now.Sub(rl.cleaned) < refill {
+		return
+	}
+	rl.cleaned = now
+	for key, b := range rl.buckets {
+		if rl.tokens(b, now) >= rl.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Second, nil)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/limited`, Func: ok}, Wrap(limiter.Middleware)),
+		{Methods: "GET", Path: `/free`, Func: ok},
+	})
+
+	var testCases = []struct {
+		wait       time.Duration
+		path       string
+		addr       string
+		wantCode   int
+		retryAfter string
+	}{
+		{0, "/limited", "10.0.0.1:1234", http.StatusOK, ""},
+		{0, "/limited", "10.0.0.1:1235", http.StatusOK, ""},
+		{0, "/limited", "10.0.0.1:1236", http.StatusTooManyRequests, "1"},
+		{0, "/limited", "10.0.0.2:1234", http.StatusOK, ""},
+		{0, "/free", "10.0.0.1:1234", http.StatusOK, ""},
+		{300 * time.Millisecond, "/limited", "10.0.0.1:1234", http.StatusTooManyRequests, "1"},
+		{200 * time.Millisecond, "/limited", "10.0.0.1:1234", http.StatusOK, ""},
+		{0, "/limited", "10.0.0.1:1234", http.StatusTooManyRequests, "1"},
+		{time.Hour, "/limited", "10.0.0.1:1234", http.StatusOK, ""},
+		{0, "/limited", "10.0.0.1:1234", http.StatusOK, ""},
+		{0, "/limited", "10.0.0.1:1234", http.StatusTooManyRequests, "1"},
+	}
+
+	for i, tc := range testCases {
+		now = now.Add(tc.wait)
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		r.RemoteAddr = tc.addr
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != tc.retryAfter {
+			t.Errorf("%d: want Retry-After %q, got %q", i, tc.retryAfter, got)
+		}
+		if w.Code != http.StatusTooManyRequests {
+			continue
+		}
+		var resp struct {
+			Errors apierr.Errors
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%d: cannot decode response: %s", i, err)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Code != "rate_limit" {
+			t.Errorf("%d: want rate_limit error, got %v", i, resp.Errors)
+		}
+	}
+
+	// full buckets of inactive clients are removed
+	now = now.Add(time.Hour)
+	limiter.take("10.0.0.3")
+	if n := len(limiter.buckets); n != 1 {
+		t.Errorf("want 1 bucket, got %d", n)
+	}
+}
+
+func TestContextKey(t *testing.T) {
+	key := ContextKey("user")
+	r := &http.Request{RemoteAddr: "10.0.0.1:1234"}
+
+	if got := key(context.Background(), r); got != "ip:10.0.0.1" {
+		t.Errorf("want ip key, got %q", got)
+	}
+	ctx := context.WithValue(context.Background(), "user", 42)
+	if got := key(ctx, r); got != "key:42" {
+		t.Errorf("want subject key, got %q", got)
+	}
+}
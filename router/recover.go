@@ -0,0 +1,57 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/optiopay/x/log"
+	"github.com/optiopay/x/web"
+	"golang.org/x/net/context"
+)
+
+// Recover return middleware that is recovering from handler panic. Panic is
+// logged together with the stack trace and pattern of the route that was
+// serving request, and standard JSON error response with 500 status code is
+// written, unless handler already started writing the response.
+//
+// If repanic is true, panic is raised again after writing the response.
+// Use it in tests, to not hide failures.
+//
+// To recover from panic of any middleware, use it as the first router
+// middleware:
+//
+//	router.New(routes, router.Use(router.Recover(false), LogRequest))
+func Recover(repanic bool) Middleware {
+	return func(fn HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// server is aborting the handler on purpose
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				log.Error("handler panic",
+					"panic", fmt.Sprint(rec),
+					"route", Pattern(ctx),
+					"method", r.Method,
+					"path", r.URL.Path,
+					"stack", string(debug.Stack()))
+				// error cannot be appended to already sent response
+				if !sw.started() {
+					web.StdErr(w, r, http.StatusInternalServerError)
+				}
+
+				if repanic {
+					panic(rec)
+				}
+			}()
+			fn(ctx, sw, r)
+		}
+	}
+}
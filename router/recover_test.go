@@ -0,0 +1,68 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestRecover(t *testing.T) {
+	routes := Routes{
+		{Methods: "GET", Path: `/panic`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}},
+		{Methods: "GET", Path: `/partial`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte("half"))
+			panic("boom")
+		}},
+		{Methods: "GET", Path: `/abort`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}},
+	}
+	serve := func(rt *Router, path string) (w *httptest.ResponseRecorder, rec interface{}) {
+		defer func() {
+			rec = recover()
+		}()
+		r, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		w = httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		return w, nil
+	}
+
+	w, rec := serve(New(routes, Use(Recover(false))), "/panic")
+	if rec != nil {
+		t.Errorf("want no panic, got %v", rec)
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("want %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	w, rec = serve(New(routes, Use(Recover(true))), "/panic")
+	if rec != "boom" {
+		t.Errorf("want panic, got %v", rec)
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("want %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	w, rec = serve(New(routes, Use(Recover(false))), "/partial")
+	if rec != nil {
+		t.Errorf("want no panic, got %v", rec)
+	}
+	if w.Code != http.StatusAccepted {
+		t.Errorf("want %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if body := w.Body.String(); body != "half" {
+		t.Errorf("want body %q, got %q", "half", body)
+	}
+
+	if _, rec = serve(New(routes, Use(Recover(false))), "/abort"); rec != http.ErrAbortHandler {
+		t.Errorf("want abort panic, got %v", rec)
+	}
+}
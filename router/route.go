@@ -0,0 +1,147 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// RouteOption declares optional setting of the route.
+type RouteOption func(*settings)
+
+// Name return option giving route a unique name, that can be used to build
+// URL path using Router.URL method.
+func Name(name string) RouteOption {
+	return func(s *settings) {
+		s.name = name
+	}
+}
+
+// Host return option setting pattern that request host must match. Text is
+// matched literally, except {name} definitions that match single domain
+// label, or custom regular expression when declared as {name:regexp}. Host
+// arguments are available after path arguments.
+func Host(pattern string) RouteOption {
+	return func(s *settings) {
+		s.host = pattern
+	}
+}
+
+// Headers return option setting header values that request must have.
+// Values are compared exactly, except Accept header that must explicitly
+// accept given media type and Content-Type header that must declare given
+// media type.
+func Headers(headers map[string]string) RouteOption {
+	return func(s *settings) {
+		s.headers = headers
+	}
+}
+
+// Wrap return option adding middlewares wrapping route handler. They are
+// executed after middlewares defined for the router and the group.
+func Wrap(middlewares ...Middleware) RouteOption {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middlewares...)
+	}
+}
+
+// QueryParams return option declaring query string parameters of the route.
+// They are validated after all middlewares are executed, right before
+// calling route handler. Use Query function to access parsed values.
+func QueryParams(params ...Param) RouteOption {
+	return func(s *settings) {
+		s.query = append(s.query, params...)
+	}
+}
+
+// With return route with given optional settings. Settings are carried by
+// the handler function of returned route, so that Route keeps its three
+// fields and can be declared with positional literal:
+//
+//	router.Routes{
+//		{"GET", `/users`, HandleListUsers},
+//		router.With(router.Route{Methods: "GET", Path: `/users/{id:\d+}`, Func: HandleUserDetails},
+//			router.Name("user"), router.Wrap(RequireAdmin)),
+//	}
+//
+// Route returned by With can be given further settings with With, but group
+// cannot.
+func With(route Route, opts ...RouteOption) Route {
+	c := &routeConfig{fn: route.Func}
+	if prev := configOf(route); prev != nil {
+		if prev.isGroup {
+			panic("cannot use With on group route")
+		}
+		c.fn = prev.fn
+		c.settings = prev.settings.clone()
+	}
+	for _, opt := range opts {
+		opt(&c.settings)
+	}
+	route.Func = c.serve
+	return route
+}
+
+// settings are optional settings of the route.
+type settings struct {
+	name       string
+	host       string
+	headers    map[string]string
+	middleware []Middleware
+	query      []Param
+}
+
+func (s settings) clone() settings {
+	s.middleware = append([]Middleware(nil), s.middleware...)
+	s.query = append([]Param(nil), s.query...)
+	return s
+}
+
+// route is a route with its optional settings resolved.
+type route struct {
+	Route
+	settings
+}
+
+// routeConfig is holding settings of the route created with With or Group
+// function. It's reachable only through handler function of the route.
+type routeConfig struct {
+	fn HandlerFunc
+	settings
+	group   Routes
+	isGroup bool
+}
+
+func (c *routeConfig) serve(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if cw, ok := w.(*configWriter); ok {
+		cw.config = c
+		return
+	}
+	if c.isGroup {
+		handleNotFound(ctx, w, r)
+		return
+	}
+	c.fn(ctx, w, r)
+}
+
+// configWriter is passed to routeConfig.serve to get the configuration.
+type configWriter struct {
+	http.ResponseWriter
+	config *routeConfig
+}
+
+// configFunc is the code pointer shared by all routeConfig.serve method
+// values, used to recognize them without calling other handler functions.
+var configFunc = reflect.ValueOf((&routeConfig{}).serve).Pointer()
+
+// configOf return settings of the route created with With or Group
+// function, or nil for any other route.
+func configOf(r Route) *routeConfig {
+	if r.Func == nil || reflect.ValueOf(r.Func).Pointer() != configFunc {
+		return nil
+	}
+	cw := &configWriter{}
+	r.Func(nil, cw, nil)
+	return cw.config
+}
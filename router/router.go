@@ -1,12 +1,19 @@
 // Package router implements regexp based HTTP router.
+//
+// Routes are compiled into a prefix tree of path segments, so that plain and
+// {name} segments do not require regular expression evaluation. Regular
+// expressions are used only for segments with custom {name:regexp} rules.
+// No matter how the tree is organized, when more than one route is matching,
+// the one declared first is used.
 package router
 
 import (
 	"fmt"
 	"net/http"
-	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/optiopay/x/web"
 	"golang.org/x/net/context"
 )
 
@@ -14,7 +21,8 @@ type Routes []Route
 
 type HandlerFunc func(context.Context, http.ResponseWriter, *http.Request)
 
-// Route binds together HTTP method, path and handler function.
+// Route binds together HTTP method, path and handler function. Optional
+// settings, like name or middlewares, are declared with With function.
 type Route struct {
 	// Method is string that can represent one or more, coma separated HTTP
 	// methods that this route should match.
@@ -27,73 +35,264 @@ type Route struct {
 	Func HandlerFunc
 }
 
-// AnyMethod is shortcut definition for
-var AnyMethod = "GET,POST,PUT,DELETE"
+// AnyMethod is shortcut definition for route matching any HTTP method.
+var AnyMethod = "*"
+
+// standardMethods is the list of methods that route declared with AnyMethod
+// is reporting as allowed.
+var standardMethods = []string{"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT"}
+
+// Option modifies router configuration.
+type Option func(*Router)
+
+// NotFound return option setting handler used to serve requests that no route
+// is matching. By default, standard JSON error response with 404 status code
+// is written.
+func NotFound(fn HandlerFunc) Option {
+	return func(rt *Router) {
+		rt.notFound = fn
+	}
+}
+
+// ProblemJSON return option making the router send all errors, including
+// errors written by handlers using web.Err, as RFC 7807 problem details
+// documents. Without this option, problem details are send only to clients
+// that prefer them, as declared with Accept header.
+func ProblemJSON() Option {
+	return func(rt *Router) {
+		rt.problem = true
+	}
+}
 
 // New create and return immutable router instance.
-func New(routes Routes) *Router {
-	handlers := make(map[string][]handler)
-	builder := regexp.MustCompile("{.*?}")
-	// ReplaceAllString
-
-	for _, r := range routes {
-		var names []string
-		raw := builder.ReplaceAllStringFunc(r.Path, func(s string) string {
-			s = s[1 : len(s)-1]
-			// every {<name>} can be optionally contain separate regexp
-			// definition using notation {<name>:<regexp>}
-			chunks := strings.SplitN(s, ":", 2)
-			if len(chunks) == 1 {
-				names = append(names, s)
-				return `([^/]+)`
-			}
-			names = append(names, chunks[0])
-			return `(` + chunks[1] + `)`
-		})
-		// replace {} with regular expressions syntax
-		rx, err := regexp.Compile(`^` + raw + `$`)
+func New(routes Routes, opts ...Option) *Router {
+	rt := &Router{
+		root:       newNode(0),
+		templates:  make(map[string]*template),
+		notFound:   handleNotFound,
+		notAllowed: handleMethodNotAllowed,
+		options:    handleOptions,
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	for i, r := range flatten(routes) {
+		cond, hostNames, err := newConditions(r)
 		if err != nil {
+			panic(fmt.Sprintf("invalid routing host %q: %s", r.host, err))
+		}
+		fn := r.Func
+		if len(r.query) != 0 {
+			if err := checkParams(r.query); err != nil {
+				panic(fmt.Sprintf("invalid query of %q route: %s", r.Path, err))
+			}
+			fn = checkQuery(r.query, fn)
+		}
+		h := &handler{
+			index:      i,
+			methods:    strings.Split(r.Methods, ","),
+			pattern:    r.Path,
+			conditions: cond,
+			fn:         chain(chain(fn, r.middleware...), rt.middlewares...),
+		}
+		if err := rt.root.insert(r.Path, h); err != nil {
 			panic(fmt.Sprintf("invalid routing path %q: %s", r.Path, err))
 		}
-		for _, method := range strings.Split(r.Methods, ",") {
-			handlers[method] = append(handlers[method], handler{
-				rx:    rx,
-				names: names,
-				fn:    r.Func,
-			})
+		h.names = append(h.names, hostNames...)
+		rt.endpoints = append(rt.endpoints, newEndpoints(r)...)
+
+		if r.name == "" {
+			continue
+		}
+		if _, ok := rt.templates[r.name]; ok {
+			panic(fmt.Sprintf("duplicated route name %q", r.name))
 		}
+		t, err := newTemplate(r.Path)
+		if err != nil {
+			panic(fmt.Sprintf("cannot use %q routing path as %q template: %s", r.Path, r.name, err))
+		}
+		rt.templates[r.name] = t
 	}
-	return &Router{
-		handlers: handlers,
-	}
+	rt.notFound = chain(rt.notFound, rt.middlewares...)
+	rt.notAllowed = chain(rt.notAllowed, rt.middlewares...)
+	rt.options = chain(rt.options, rt.middlewares...)
+	return rt
 }
 
 type Router struct {
-	handlers map[string][]handler // method => route
+	root        *node
+	templates   map[string]*template // route name => template
+	endpoints   []Endpoint
+	middlewares []Middleware
+	notFound    HandlerFunc
+	notAllowed  HandlerFunc
+	options     HandlerFunc
+	slash       SlashPolicy
+	problem     bool
 }
 
-// ServeHTTP handle HTTP request using empty context.
+// ServeHTTP handle HTTP request using request context, so that request
+// cancellation and deadline are propagated to the handler.
 func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
-	rt.ServeCtxHTTP(ctx, w, r)
+	rt.ServeCtxHTTP(r.Context(), w, r)
 }
 
-// ServeCtxHTTP handle HTTP request using given context.
+// ServeCtxHTTP handle HTTP request using given context. Router values, like
+// path arguments, are added to both given context and the request context,
+// so that they can be accessed using any of them, while r.Context() keeps
+// request cancellation and values set by the server. If given context
+// already carries path arguments, they are available after arguments matched
+// by this router.
+//
+// HEAD requests are served by GET handler with response body discarded,
+// unless route explicitly accepting HEAD method is matching. OPTIONS requests
+// are answered with the list of methods allowed for the path, unless route
+// explicitly accepting OPTIONS method is matching.
+//
+// If path is matching at least one route, but none of them accepts request
+// method, 405 response with Allow header is written. If no route is matching
+// request path, not found handler is called.
 func (rt *Router) ServeCtxHTTP(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	for _, h := range rt.handlers[r.Method] {
-		match := h.rx.FindAllStringSubmatch(r.URL.Path, 1)
-		if len(match) == 0 {
-			continue
+	if rt.problem {
+		ctx, r = withContext(ctx, r, web.WithProblemJSON)
+	}
+
+	// when path contains encoded characters that must be preserved, like
+	// encoded slash, escaped path is matched and values are decoded later
+	path := r.URL.Path
+	if r.URL.RawPath != "" {
+		path = decodeUnreserved(r.URL.EscapedPath())
+	}
+
+	target := cleanPath(path)
+	h, values, allowed := rt.match(target, r)
+	if h == nil && len(allowed) == 0 && rt.slash != SlashStrict {
+		if alt := toggleSlash(target); alt != "" {
+			if ah, av, aa := rt.match(alt, r); ah != nil || len(aa) != 0 {
+				target, h, values, allowed = alt, ah, av, aa
+			}
 		}
-		values := match[0]
+	}
+	if target != path {
+		// redirect only if client can be served at the new location
+		if rt.slash == SlashRedirect && h != nil {
+			redirect(ctx, w, r, target)
+			return
+		}
+		r = withPath(r, target)
+	}
 
-		ctx = context.WithValue(ctx, "router:args", &args{
-			names:  h.names,
-			values: values[1:],
-		})
-		h.fn(ctx, w, r)
+	if r.Method == "HEAD" && (h == nil || !h.hasMethod("HEAD")) {
+		w = &headResponseWriter{w}
+	}
+	if h == nil {
+		if len(allowed) == 0 {
+			rt.notFound(ctx, w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if r.Method == "OPTIONS" {
+			rt.options(ctx, w, r)
+		} else {
+			rt.notAllowed(ctx, w, r)
+		}
 		return
 	}
+	a := &args{
+		names:  h.names,
+		values: values,
+	}
+	// arguments of the router this router is mounted in
+	if parent, ok := ctx.Value("router:args").(*args); ok {
+		a.names = append(append([]string(nil), h.names...), parent.names...)
+		a.values = append(values, parent.values...)
+	}
+	mounted, _ := ctx.Value("router:mount").(string)
+	ctx, r = withValue(ctx, r, "router:pattern", mounted+h.pattern)
+	ctx, r = withValue(ctx, r, "router:args", a)
+	h.fn(ctx, w, r)
+}
+
+// withContext return handler function context and request with context
+// derived from their current contexts with given function. When both share
+// the same context, they keep sharing it.
+func withContext(ctx context.Context, r *http.Request, fn func(context.Context) context.Context) (context.Context, *http.Request) {
+	if ctx == r.Context() {
+		ctx = fn(ctx)
+		return ctx, r.WithContext(ctx)
+	}
+	return fn(ctx), r.WithContext(fn(r.Context()))
+}
+
+// withValue return handler function context and request, both carrying
+// given router value.
+func withValue(ctx context.Context, r *http.Request, key string, value interface{}) (context.Context, *http.Request) {
+	return withContext(ctx, r, func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key, value)
+	})
+}
+
+func handleNotFound(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	web.StdErr(w, r, http.StatusNotFound)
+}
+
+func handleMethodNotAllowed(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	web.StdErr(w, r, http.StatusMethodNotAllowed)
+}
+
+func handleOptions(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// match return handler of the route serving given path and request together
+// with matched values. If no route is accepting request method, list of
+// methods allowed for the path is returned instead.
+func (rt *Router) match(path string, r *http.Request) (*handler, []string, []string) {
+	h, values := rt.root.lookup(path, r.Method, r)
+	if h == nil && r.Method == "HEAD" {
+		h, values = rt.root.lookup(path, "GET", r)
+	}
+	if h != nil {
+		return h, values, nil
+	}
+	return nil, nil, rt.allowed(path, r)
+}
+
+// allowed return sorted list of methods that can be used to request given
+// path. Methods that are handled by the router automatically are included.
+func (rt *Router) allowed(path string, r *http.Request) []string {
+	allow := rt.root.allowed(path, r)
+	if len(allow) == 0 {
+		return nil
+	}
+	if allow[AnyMethod] {
+		delete(allow, AnyMethod)
+		for _, m := range standardMethods {
+			allow[m] = true
+		}
+	}
+	if allow["GET"] {
+		allow["HEAD"] = true
+	}
+	allow["OPTIONS"] = true
+
+	methods := make([]string, 0, len(allow))
+	for m := range allow {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// headResponseWriter discards response body, so that HEAD request can be
+// served by GET handler.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
 
 type args struct {
@@ -142,16 +341,39 @@ func (a *args) ByIndex(n int) string {
 }
 
 type handler struct {
-	rx    *regexp.Regexp
+	// index is the route position in declaration order
+	index      int
+	methods    []string
+	pattern    string
+	conditions *conditions
+	// names contains names of path arguments followed by names of host
+	// arguments
 	names []string
 	fn    HandlerFunc
 }
 
+// match return true if given request fulfills route conditions other than
+// method and path.
+func (h *handler) match(r *http.Request) bool {
+	return h.conditions == nil || h.conditions.match(r)
+}
+
+func (h *handler) hasMethod(method string) bool {
+	for _, m := range h.methods {
+		if m == method || m == AnyMethod {
+			return true
+		}
+	}
+	return false
+}
+
 // Args return PathArgs carried by given context.
 func Args(ctx context.Context) PathArgs {
 	return ctx.Value("router:args").(*args)
 }
 
+// PathArgs provides access to values matched by path arguments. Use Int64,
+// UUID, Time and Enum functions to parse them.
 type PathArgs interface {
 	ByName(string) string
 	ByIndex(int) string
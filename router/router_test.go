@@ -1,10 +1,16 @@
 package router
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/web"
 	"golang.org/x/net/context"
 )
 
@@ -103,3 +109,500 @@ func has(a []string, s string) bool {
 	}
 	return false
 }
+
+func TestWith(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(fn HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				fn(ctx, w, r)
+			}
+		}
+	}
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}
+
+	user := With(Route{"GET", `/users/{id}`, handler}, Name("user"), Wrap(middleware("a")))
+	rt := New(Routes{
+		With(user, Wrap(middleware("b"))),
+		{"GET", `/users`, handler},
+	})
+
+	r, _ := http.NewRequest("GET", "/users/1", nil)
+	rt.ServeHTTP(httptest.NewRecorder(), r)
+	if want := []string{"a", "b", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("want calls %v, got %v", want, calls)
+	}
+	if path, err := rt.URL("user", "id", "1"); err != nil || path != "/users/1" {
+		t.Errorf("want /users/1 path, got %q: %v", path, err)
+	}
+
+	// handler function of the route can be still called directly
+	calls = nil
+	user.Func(context.Background(), httptest.NewRecorder(), r)
+	if want := []string{"handler"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("want calls %v, got %v", want, calls)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("want panic")
+		}
+	}()
+	With(Group(`/v1`, nil), Name("v1"))
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	noop := func(context.Context, http.ResponseWriter, *http.Request) {}
+	rt := New(Routes{
+		{Methods: "GET,PUT", Path: `/users/{id}`, Func: noop},
+		{Methods: "DELETE", Path: `/users/{id:\d+}`, Func: noop},
+		{Methods: "POST", Path: `/users`, Func: noop},
+	})
+
+	var testCases = []struct {
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+	}{
+		{"GET", "/users/1", http.StatusOK, ""},
+		{"POST", "/users/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PUT"},
+		{"POST", "/users/foo", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, PUT"},
+		{"GET", "/users", http.StatusMethodNotAllowed, "OPTIONS, POST"},
+		{"GET", "/accounts", http.StatusNotFound, ""},
+	}
+
+	for i, tc := range testCases {
+		r, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.wantAllow {
+			t.Errorf("%d: want Allow %q, got %q", i, tc.wantAllow, allow)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	r, err := http.NewRequest("GET", "/foo", nil)
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	New(nil).ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, w.Code)
+	}
+	var resp struct {
+		Errors apierr.Errors `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("cannot decode response: %s", err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Code != "not_found" {
+		t.Errorf("unexpected response: %#v", resp.Errors)
+	}
+
+	w = httptest.NewRecorder()
+	New(nil, NotFound(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot {
+		t.Errorf("want %d, got %d", http.StatusTeapot, w.Code)
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/users`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			web.Err(w, r, apierr.Errors{}.WithForbidden(""), http.StatusForbidden)
+		}}, QueryParams(Page())),
+	}, ProblemJSON())
+
+	var testCases = []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"GET", "/missing", http.StatusNotFound},
+		{"POST", "/users", http.StatusMethodNotAllowed},
+		{"GET", "/users?page=0", http.StatusBadRequest},
+		{"GET", "/users", http.StatusForbidden},
+	}
+
+	for i, tc := range testCases {
+		r, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=UTF-8" {
+			t.Errorf("%d: want problem content type, got %q", i, ct)
+		}
+		var p web.Problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Fatalf("%d: cannot decode response: %s", i, err)
+		}
+		if p.Status != tc.wantCode || len(p.Errors) != 1 {
+			t.Errorf("%d: unexpected response: %+v", i, p)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(fn HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+":"+Pattern(ctx))
+				fn(ctx, w, r)
+			}
+		}
+	}
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}
+
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/users/{id}`, Func: handler}, Wrap(middleware("r1")), Wrap(middleware("r2"))),
+		{Methods: "GET", Path: `/users`, Func: handler},
+	}, Use(middleware("g1"), middleware("g2")))
+
+	var testCases = []struct {
+		path      string
+		wantCalls []string
+	}{
+		{"/users/1", []string{"g1:/users/{id}", "g2:/users/{id}", "r1:/users/{id}", "r2:/users/{id}", "handler"}},
+		{"/users", []string{"g1:/users", "g2:/users", "handler"}},
+		{"/accounts", []string{"g1:", "g2:"}},
+	}
+
+	for i, tc := range testCases {
+		calls = nil
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		rt.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(calls, tc.wantCalls) {
+			t.Errorf("%d: want calls %v, got %v", i, tc.wantCalls, calls)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(fn HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				fn(ctx, w, r)
+			}
+		}
+	}
+	handler := func(names ...string) HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			for _, name := range names {
+				calls = append(calls, name+"="+Args(ctx).ByName(name))
+			}
+		}
+	}
+
+	rt := New(Routes{
+		Group(`/v1/accounts/{id:\d+}`, Routes{
+			{Methods: "GET", Path: `/balance`, Func: handler("id")},
+			Group(`/transactions`, Routes{
+				With(Route{Methods: "GET", Path: `/{tx}`, Func: handler("id", "tx")}, Wrap(middleware("route"))),
+			}, middleware("inner")),
+		}, middleware("outer")),
+		{Methods: "GET", Path: `/v1/{any}/balance`, Func: handler("any")},
+	}, Use(middleware("router")))
+
+	var testCases = []struct {
+		path      string
+		wantCalls []string
+	}{
+		{"/v1/accounts/12/balance", []string{"router", "outer", "id=12"}},
+		{"/v1/accounts/12/transactions/ab", []string{"router", "outer", "inner", "route", "id=12", "tx=ab"}},
+		{"/v1/users/balance", []string{"router", "any=users"}},
+	}
+
+	for i, tc := range testCases {
+		calls = nil
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		rt.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(calls, tc.wantCalls) {
+			t.Errorf("%d: want calls %v, got %v", i, tc.wantCalls, calls)
+		}
+	}
+}
+
+func TestEmptyGroup(t *testing.T) {
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+	rt := New(Routes{
+		Group(`/v1`, nil),
+		Group(`/v1`, Routes{}),
+		{Methods: "GET", Path: `/v1`, Func: noop},
+	})
+	if n := len(rt.Routes()); n != 1 {
+		t.Errorf("want 1 endpoint, got %d", n)
+	}
+
+	r, err := http.NewRequest("POST", "/v1", nil)
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+	if want, got := "GET, HEAD, OPTIONS", w.Header().Get("Allow"); got != want {
+		t.Errorf("want Allow %q, got %q", want, got)
+	}
+}
+
+func TestAutomaticMethods(t *testing.T) {
+	handler := func(name string) HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Handler", name)
+			_, _ = w.Write([]byte(name))
+		}
+	}
+	rt := New(Routes{
+		{Methods: "GET", Path: `/users`, Func: handler("users")},
+		{Methods: "HEAD", Path: `/accounts`, Func: handler("accounts-head")},
+		{Methods: "GET", Path: `/accounts`, Func: handler("accounts")},
+		{Methods: "OPTIONS", Path: `/cors`, Func: handler("cors")},
+		{Methods: AnyMethod, Path: `/any`, Func: handler("any")},
+	})
+
+	var testCases = []struct {
+		method      string
+		path        string
+		wantCode    int
+		wantHandler string
+		wantBody    string
+		wantAllow   string
+	}{
+		{"HEAD", "/users", http.StatusOK, "users", "", ""},
+		{"HEAD", "/accounts", http.StatusOK, "accounts-head", "accounts-head", ""},
+		{"OPTIONS", "/users", http.StatusNoContent, "", "", "GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/cors", http.StatusOK, "cors", "cors", ""},
+		{"OPTIONS", "/missing", http.StatusNotFound, "", "", ""},
+		{"PATCH", "/any", http.StatusOK, "any", "any", ""},
+		{"HEAD", "/any", http.StatusOK, "any", "any", ""},
+		{"OPTIONS", "/any", http.StatusOK, "any", "any", ""},
+		{"PATCH", "/users", http.StatusMethodNotAllowed, "", "", "GET, HEAD, OPTIONS"},
+	}
+
+	for i, tc := range testCases {
+		r, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if h := w.Header().Get("Handler"); h != tc.wantHandler {
+			t.Errorf("%d: want handler %q, got %q", i, tc.wantHandler, h)
+		}
+		if tc.wantCode == http.StatusOK && w.Body.String() != tc.wantBody {
+			t.Errorf("%d: want body %q, got %q", i, tc.wantBody, w.Body.String())
+		}
+		if allow := w.Header().Get("Allow"); allow != tc.wantAllow {
+			t.Errorf("%d: want Allow %q, got %q", i, tc.wantAllow, allow)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/users`}, Name("users")),
+		Group(`/users/{id:\d+}`, Routes{
+			{Methods: "GET,PUT", Path: `/files/{name}`},
+		}),
+		{Methods: AnyMethod, Path: `/v[12]/ping`},
+		Mount(`/static`, nil),
+	})
+	want := []Endpoint{
+		{Method: "GET", Pattern: `/users`, Methods: []string{"GET"}, Template: `/users`, Name: "users"},
+		{Method: "GET", Pattern: `/users/{id:\d+}/files/{name}`, Methods: []string{"GET"}, Template: `/users/{id}/files/{name}`,
+			Args: []Argument{{"id", `\d+`}, {"name", `[^/]+`}}},
+		{Method: "PUT", Pattern: `/users/{id:\d+}/files/{name}`, Methods: []string{"PUT"}, Template: `/users/{id}/files/{name}`,
+			Args: []Argument{{"id", `\d+`}, {"name", `[^/]+`}}},
+		{Method: "*", Pattern: `/v[12]/ping`, Methods: standardMethods},
+		{Method: "*", Pattern: `/static{*:(?:/.*)?}`, Methods: standardMethods, Template: `/static`, Mount: true,
+			Args: []Argument{{"*", `(?:/.*)?`}}},
+	}
+	if got := rt.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	handler := func(name string) HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			a := Args(ctx).(*args)
+			fmt.Fprintf(w, "%s %v", name, a.values)
+		}
+	}
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/users/{id}`, Func: handler("partner")}, Host(`{partner}.partners.example.com`)),
+		With(Route{Methods: "GET", Path: `/users/{id}`, Func: handler("v2")}, Headers(map[string]string{"x-api-version": "2"})),
+		With(Route{Methods: "GET", Path: `/users/{id}`, Func: handler("csv")}, Headers(map[string]string{"Accept": "text/csv"})),
+		{Methods: "GET", Path: `/users/{id}`, Func: handler("default")},
+		With(Route{Methods: "POST", Path: `/users`, Func: handler("json")}, Headers(map[string]string{"Content-Type": "application/json"})),
+		With(Route{Methods: "GET", Path: `/admin`, Func: handler("admin")}, Host(`admin.example.com`)),
+	})
+
+	var testCases = []struct {
+		method   string
+		host     string
+		path     string
+		headers  map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{"GET", "acme.partners.example.com:8000", "/users/1", nil, http.StatusOK, "partner [1 acme]"},
+		{"GET", "ACME.Partners.example.com", "/users/1", nil, http.StatusOK, "partner [1 ACME]"},
+		{"GET", "example.com", "/users/1", map[string]string{"X-Api-Version": "2"}, http.StatusOK, "v2 [1]"},
+		{"GET", "example.com", "/users/1", map[string]string{"X-Api-Version": "3"}, http.StatusOK, "default [1]"},
+		{"GET", "example.com", "/users/1", map[string]string{"Accept": "text/*;q=0.5, application/json"}, http.StatusOK, "csv [1]"},
+		{"GET", "example.com", "/users/1", map[string]string{"Accept": "text/csv;q=0, application/json"}, http.StatusOK, "default [1]"},
+		{"GET", "example.com", "/users/1", map[string]string{"Accept": "*/*"}, http.StatusOK, "default [1]"},
+		{"POST", "example.com", "/users", map[string]string{"Content-Type": "application/json; charset=utf-8"}, http.StatusOK, "json []"},
+		{"POST", "example.com", "/users", map[string]string{"Content-Type": "text/plain"}, http.StatusNotFound, ""},
+		{"GET", "admin.example.com", "/admin", nil, http.StatusOK, "admin []"},
+		{"GET", "www.example.com", "/admin", nil, http.StatusNotFound, ""},
+		{"POST", "admin.example.com", "/admin", nil, http.StatusMethodNotAllowed, ""},
+	}
+
+	for i, tc := range testCases {
+		r, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		r.Host = tc.host
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tc.wantCode {
+			t.Errorf("%d: want %d, got %d", i, tc.wantCode, w.Code)
+		}
+		if tc.wantCode == http.StatusOK && w.Body.String() != tc.wantBody {
+			t.Errorf("%d: want body %q, got %q", i, tc.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestStdInterop(t *testing.T) {
+	type key string
+
+	std := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %v", Args(r.Context()).ByName("id"), r.Context().Value(key("mw")), r.Context().Err())
+	})
+	stdMiddleware := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), key("mw"), "std")))
+		})
+	}
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/std/{id}`, Func: Handler(std)}, Wrap(StdMiddleware(stdMiddleware))),
+		{Methods: "GET", Path: `/ctx/{id}`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if ctx != r.Context() {
+				t.Error("handler context is not the request context")
+			}
+			fmt.Fprintf(w, "%s %v", Args(ctx).ByName("id"), ctx.Err())
+		}},
+	})
+
+	var testCases = []struct {
+		path     string
+		cancel   bool
+		wantBody string
+	}{
+		{"/std/1", false, "1 std <nil>"},
+		{"/std/2", true, "2 std context canceled"},
+		{"/ctx/3", true, "3 context canceled"},
+	}
+
+	for i, tc := range testCases {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tc.cancel {
+			cancel()
+		}
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r.WithContext(ctx))
+		cancel()
+		if w.Body.String() != tc.wantBody {
+			t.Errorf("%d: want body %q, got %q", i, tc.wantBody, w.Body.String())
+		}
+	}
+
+	// router handler functions can be used as http.Handler
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}).ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot {
+		t.Errorf("want %d, got %d", http.StatusTeapot, w.Code)
+	}
+}
+
+func TestServeCtxHTTPRequestContext(t *testing.T) {
+	type key string
+
+	rt := New(Routes{
+		{Methods: "GET", Path: `/users/{id}`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			rctx := r.Context()
+			fmt.Fprintf(w, "%s %s %s %v %v %v",
+				Args(ctx).ByName("id"), Args(rctx).ByName("id"), Pattern(rctx),
+				ctx.Value(key("app")), rctx.Done() != nil, rctx.Value(http.ServerContextKey) != nil)
+		}},
+	})
+	// application context, not derived from the request context
+	app := context.WithValue(context.Background(), key("app"), "app")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.ServeCtxHTTP(app, w, r)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/users/42")
+	if err != nil {
+		t.Fatalf("cannot get: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("cannot read body: %s", err)
+	}
+	if want := "42 42 /users/{id} app true true"; string(body) != want {
+		t.Errorf("want body %q, got %q", want, body)
+	}
+}
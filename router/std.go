@@ -0,0 +1,32 @@
+package router
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// ServeHTTP implements http.Handler interface, so that handler function can
+// be used with any standard library compatible code. Request context is
+// passed to the handler function.
+func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fn(r.Context(), w, r)
+}
+
+// Handler return handler function serving requests with given http.Handler.
+// Request passed to the handler carries handler function context, so that
+// path arguments are available using Args(r.Context()).
+func Handler(h http.Handler) HandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// StdMiddleware return middleware wrapping handler function with given
+// standard library compatible middleware. Context of the request passed down
+// by the middleware is used as the handler function context.
+func StdMiddleware(mw func(http.Handler) http.Handler) Middleware {
+	return func(fn HandlerFunc) HandlerFunc {
+		return Handler(mw(fn))
+	}
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+// Swappable is serving requests using router that can be replaced at any
+// time, for example to enable new routes without restarting the application.
+//
+// Every request is served by the router that was current when the request
+// arrived. Replacing the router does not affect requests that are already
+// being served, they finish using the old one.
+type Swappable struct {
+	current atomic.Value
+}
+
+// NewSwappable return swappable router serving requests with given router.
+func NewSwappable(rt *Router) *Swappable {
+	if rt == nil {
+		panic("router must not be nil")
+	}
+	s := &Swappable{}
+	s.current.Store(rt)
+	return s
+}
+
+// Swap atomically replace router used to serve requests and return the
+// previously used one.
+func (s *Swappable) Swap(rt *Router) *Router {
+	if rt == nil {
+		panic("router must not be nil")
+	}
+	return s.current.Swap(rt).(*Router)
+}
+
+// Router return router currently used to serve requests.
+func (s *Swappable) Router() *Router {
+	return s.current.Load().(*Router)
+}
+
+func (s *Swappable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Router().ServeCtxHTTP(r.Context(), w, r)
+}
+
+// ServeCtxHTTP serve request using current router. Use this method to mount
+// swappable router.
+func (s *Swappable) ServeCtxHTTP(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	s.Router().ServeCtxHTTP(ctx, w, r)
+}
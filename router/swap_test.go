@@ -0,0 +1,130 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func versionRouter(version int) *Router {
+	return New(Routes{
+		{Methods: "GET", Path: `/version`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, version)
+		}},
+		{Methods: "GET", Path: fmt.Sprintf(`/feature/%d`, version), Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, version)
+		}},
+	})
+}
+
+func TestSwappable(t *testing.T) {
+	s := NewSwappable(versionRouter(1))
+
+	get := func(path string) (int, string) {
+		r, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	if code, body := get("/version"); code != http.StatusOK || body != "1" {
+		t.Errorf("want 200 1, got %d %s", code, body)
+	}
+	if code, _ := get("/feature/2"); code != http.StatusNotFound {
+		t.Errorf("want 404, got %d", code)
+	}
+
+	old := s.Swap(versionRouter(2))
+	if old == nil || old == s.Router() {
+		t.Error("want previous router returned")
+	}
+	if code, body := get("/feature/2"); code != http.StatusOK || body != "2" {
+		t.Errorf("want 200 2, got %d %s", code, body)
+	}
+	if code, _ := get("/feature/1"); code != http.StatusNotFound {
+		t.Errorf("want 404, got %d", code)
+	}
+}
+
+func TestSwappableInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := NewSwappable(New(Routes{
+		{Methods: "GET", Path: `/slow`, Func: func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			fmt.Fprint(w, "old")
+		}},
+	}))
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r, _ := http.NewRequest("GET", "/slow", nil)
+		s.ServeHTTP(w, r)
+	}()
+
+	<-started
+	s.Swap(versionRouter(2))
+	close(release)
+	<-done
+
+	if w.Code != http.StatusOK || w.Body.String() != "old" {
+		t.Errorf("want request finished by old router, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// TestSwappableConcurrent is meant to be run with -race flag, to ensure that
+// swapping routers while serving requests is safe.
+func TestSwappableConcurrent(t *testing.T) {
+	s := NewSwappable(versionRouter(0))
+
+	const (
+		workers  = 8
+		requests = 200
+		swaps    = 50
+	)
+
+	var wg sync.WaitGroup
+	errc := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := -1
+			for j := 0; j < requests; j++ {
+				r, _ := http.NewRequest("GET", "/version", nil)
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, r)
+				var version int
+				if _, err := fmt.Sscan(w.Body.String(), &version); err != nil || w.Code != http.StatusOK {
+					errc <- fmt.Errorf("invalid response: %d %q", w.Code, w.Body.String())
+					return
+				}
+				// routers are swapped in increasing order
+				if version < last {
+					errc <- fmt.Errorf("version %d served after %d", version, last)
+					return
+				}
+				last = version
+			}
+		}()
+	}
+	for i := 1; i <= swaps; i++ {
+		s.Swap(versionRouter(i))
+	}
+	wg.Wait()
+	close(errc)
+
+	for err := range errc {
+		t.Error(err)
+	}
+}
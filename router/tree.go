@@ -0,0 +1,255 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// node is a single element of the prefix tree used to match request path.
+// Every level of the tree consumes one, slash separated path segment.
+//
+// Plain segments are matched by string comparison, {name} segments match
+// any non empty segment. Anything else (custom regular expressions, segments
+// mixing text with arguments) is compiled into a regular expression that has
+// to match the whole remaining part of the path.
+type node struct {
+	static map[string]*node
+	param  *node
+	tails  []*tail
+	ends   []*handler
+
+	// min is the lowest index of the handler registered in this node or any
+	// of its children. Because handlers are added in declaration order, it
+	// is the index of the handler that created the node.
+	min int
+}
+
+// tail is regular expression based leaf of the tree.
+type tail struct {
+	rx *regexp.Regexp
+	h  *handler
+}
+
+func newNode(index int) *node {
+	return &node{min: index}
+}
+
+// insert register handler under given path pattern.
+func (n *node) insert(pattern string, h *handler) error {
+	segments := splitPattern(pattern)
+	for i, seg := range segments {
+		if isStatic(seg) {
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			child, ok := n.static[seg]
+			if !ok {
+				child = newNode(h.index)
+				n.static[seg] = child
+			}
+			n = child
+			continue
+		}
+		if name, ok := paramName(seg); ok {
+			if n.param == nil {
+				n.param = newNode(h.index)
+			}
+			h.names = append(h.names, name)
+			n = n.param
+			continue
+		}
+
+		rx, names, err := compile(strings.Join(segments[i:], "/"))
+		if err != nil {
+			return err
+		}
+		h.names = append(h.names, names...)
+		n.tails = append(n.tails, &tail{rx: rx, h: h})
+		return nil
+	}
+	n.ends = append(n.ends, h)
+	return nil
+}
+
+// lookup return the first declared handler that is accepting given method
+// and request, and its path matches, together with the list of matched
+// values.
+//
+// If request URL has RawPath set, given path must be escaped. Every segment
+// is decoded separately, so that encoded slash is never considered to be a
+// segment separator.
+func (n *node) lookup(path, method string, r *http.Request) (*handler, []string) {
+	m := matcher{method: method, req: r, escaped: r.URL.RawPath != ""}
+	m.walk(n, path, false)
+	if m.best != nil && m.best.conditions != nil {
+		m.values = append(m.values, m.best.conditions.hostValues(r)...)
+	}
+	return m.best, m.values
+}
+
+// allowed return set of methods declared by routes that are accepting given
+// request and path.
+func (n *node) allowed(path string, r *http.Request) map[string]bool {
+	m := matcher{req: r, escaped: r.URL.RawPath != "", allow: make(map[string]bool)}
+	m.walk(n, path, false)
+	return m.allow
+}
+
+type matcher struct {
+	method  string
+	req     *http.Request
+	escaped bool
+
+	// allow is set only when collecting methods of all matching routes. In
+	// such case no handler is ever selected as the best match.
+	allow map[string]bool
+
+	// values contains arguments matched by currently examined branch
+	stack  []string
+	best   *handler
+	values []string
+}
+
+// walk examine given node and all it's children. Branches that cannot
+// contain handler declared before the best match found so far are skipped.
+func (m *matcher) walk(n *node, path string, done bool) {
+	if m.best != nil && n.min >= m.best.index {
+		return
+	}
+	if done {
+		for _, h := range n.ends {
+			if m.accept(h) {
+				m.found(h, m.stack)
+				return
+			}
+		}
+		return
+	}
+
+	seg, rest, last := path, "", true
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		seg, rest, last = path[:i], path[i+1:], false
+	}
+	if m.escaped {
+		seg = unescape(seg)
+	}
+
+	if child, ok := n.static[seg]; ok {
+		m.walk(child, rest, last)
+	}
+	if n.param != nil && seg != "" {
+		m.stack = append(m.stack, seg)
+		m.walk(n.param, rest, last)
+		m.stack = m.stack[:len(m.stack)-1]
+	}
+	for _, t := range n.tails {
+		if m.best != nil && t.h.index >= m.best.index {
+			break
+		}
+		if m.allow == nil && !t.h.hasMethod(m.method) {
+			continue
+		}
+		remaining := path
+		if m.escaped {
+			remaining = unescape(path)
+		}
+		match := t.rx.FindStringSubmatch(remaining)
+		if match == nil || !m.accept(t.h) {
+			continue
+		}
+		m.found(t.h, append(m.stack, match[1:]...))
+		break
+	}
+}
+
+// accept return true if given handler of the matching route can be used to
+// serve the request.
+func (m *matcher) accept(h *handler) bool {
+	if !h.match(m.req) {
+		return false
+	}
+	if m.allow != nil {
+		for _, method := range h.methods {
+			m.allow[method] = true
+		}
+		return false
+	}
+	return h.hasMethod(m.method)
+}
+
+func (m *matcher) found(h *handler, values []string) {
+	if m.best != nil && m.best.index < h.index {
+		return
+	}
+	m.best = h
+	m.values = append(m.values[:0], values...)
+}
+
+// splitPattern split path pattern into slash separated segments. Slash
+// characters that are part of {name:regexp} definition are ignored.
+func splitPattern(pattern string) []string {
+	var (
+		segments []string
+		inside   bool
+		start    int
+	)
+	for i, c := range pattern {
+		switch c {
+		case '{':
+			inside = true
+		case '}':
+			inside = false
+		case '/':
+			if !inside {
+				segments = append(segments, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
+// isStatic return true if given segment can be matched by plain string
+// comparison.
+func isStatic(seg string) bool {
+	return regexp.QuoteMeta(seg) == seg
+}
+
+// paramName return argument name if given segment is {name} definition
+// without custom regular expression.
+func paramName(seg string) (string, bool) {
+	if len(seg) < 2 || seg[0] != '{' || seg[len(seg)-1] != '}' {
+		return "", false
+	}
+	name := seg[1 : len(seg)-1]
+	if strings.ContainsAny(name, ":{}") {
+		return "", false
+	}
+	return name, true
+}
+
+var placeholder = regexp.MustCompile("{.*?}")
+
+// compile return regular expression build from given path pattern and list
+// of argument names used in it.
+func compile(pattern string) (*regexp.Regexp, []string, error) {
+	var names []string
+	raw := placeholder.ReplaceAllStringFunc(pattern, func(s string) string {
+		s = s[1 : len(s)-1]
+		// every {<name>} can be optionally contain separate regexp
+		// definition using notation {<name>:<regexp>}
+		chunks := strings.SplitN(s, ":", 2)
+		if len(chunks) == 1 {
+			names = append(names, s)
+			return `([^/]+)`
+		}
+		names = append(names, chunks[0])
+		return `(` + chunks[1] + `)`
+	})
+	rx, err := regexp.Compile(`^` + raw + `$`)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rx, names, nil
+}
@@ -0,0 +1,171 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// linearRouter is the reference implementation of the router, that is
+// examining all routes one by one. It is used to compare results and
+// performance of the tree matcher.
+type linearRouter struct {
+	handlers map[string][]linearHandler
+}
+
+type linearHandler struct {
+	rx    *regexp.Regexp
+	names []string
+	fn    HandlerFunc
+}
+
+func newLinear(routes Routes) *linearRouter {
+	handlers := make(map[string][]linearHandler)
+	for _, r := range routes {
+		rx, names, err := compile(r.Path)
+		if err != nil {
+			panic(err)
+		}
+		for _, method := range strings.Split(r.Methods, ",") {
+			handlers[method] = append(handlers[method], linearHandler{
+				rx:    rx,
+				names: names,
+				fn:    r.Func,
+			})
+		}
+	}
+	return &linearRouter{handlers: handlers}
+}
+
+func (rt *linearRouter) ServeCtxHTTP(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	for _, h := range rt.handlers[r.Method] {
+		match := h.rx.FindAllStringSubmatch(r.URL.Path, 1)
+		if len(match) == 0 {
+			continue
+		}
+		ctx = context.WithValue(ctx, "router:args", &args{
+			names:  h.names,
+			values: match[0][1:],
+		})
+		h.fn(ctx, w, r)
+		return
+	}
+}
+
+func TestTreeMatchesLinear(t *testing.T) {
+	var got string
+	handler := func(id int) HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			a := Args(ctx).(*args)
+			got = fmt.Sprintf("%d %v %v", id, a.names, a.values)
+		}
+	}
+	routes := Routes{
+		{Methods: "GET", Path: `/`, Func: handler(1)},
+		{Methods: "GET", Path: `/a/{x:\d+}`, Func: handler(2)},
+		{Methods: "GET", Path: `/a/{x}`, Func: handler(3)},
+		{Methods: "GET", Path: `/a/b`, Func: handler(4)},
+		{Methods: "GET,POST", Path: `/a/{x}/c`, Func: handler(5)},
+		{Methods: "GET", Path: `/a/b/c`, Func: handler(6)},
+		{Methods: "GET", Path: `/f/{x}.{ext:json|xml}`, Func: handler(7)},
+		{Methods: "GET", Path: `/f/{name}`, Func: handler(8)},
+		{Methods: "POST", Path: `/p/{path:.*}`, Func: handler(9)},
+		{Methods: "GET", Path: `/s/{a}/{b}/`, Func: handler(10)},
+		{Methods: "GET", Path: `/s/{a}/{b}`, Func: handler(11)},
+		{Methods: "GET", Path: `/t/{any:[a-z/]+}/end`, Func: handler(12)},
+		{Methods: "PUT", Path: `.*`, Func: handler(13)},
+		{Methods: "PUT", Path: `/a/b`, Func: handler(14)},
+	}
+	tree := New(routes)
+	linear := newLinear(routes)
+
+	paths := []string{
+		"/", "", "/a", "/a/", "/a/b", "/a/42", "/a/42/c", "/a/b/c", "/a/b/c/",
+		"/f/x.json", "/f/x.txt", "/f/", "/p/", "/p/a/b/c", "/s/1/2",
+		"/s/1/2/", "/s//2", "/t/a/b/end", "/t/a/b/", "/x/y/z",
+	}
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+		for _, path := range paths {
+			r := &http.Request{Method: method, URL: &url.URL{Path: path}}
+
+			got = ""
+			linear.ServeCtxHTTP(context.Background(), httptest.NewRecorder(), r)
+			want := got
+
+			got = ""
+			tree.ServeCtxHTTP(context.Background(), httptest.NewRecorder(), r)
+			if got != want {
+				t.Errorf("%s %q: want %q, got %q", method, path, want, got)
+			}
+		}
+	}
+}
+
+func benchRoutes(n int) Routes {
+	noop := func(context.Context, http.ResponseWriter, *http.Request) {}
+	var routes Routes
+	for i := 0; i < n; i++ {
+		routes = append(routes,
+			Route{Methods: "GET", Path: fmt.Sprintf("/v1/resource%d", i), Func: noop},
+			Route{Methods: "GET", Path: fmt.Sprintf("/v1/resource%d/{id}", i), Func: noop},
+			Route{Methods: "PUT", Path: fmt.Sprintf(`/v1/resource%d/{id:\d+}/items/{item}`, i), Func: noop},
+		)
+	}
+	return routes
+}
+
+type ctxServer interface {
+	ServeCtxHTTP(context.Context, http.ResponseWriter, *http.Request)
+}
+
+func benchServe(b *testing.B, rt ctxServer, method, path string) {
+	r, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		b.Fatalf("cannot create request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeCtxHTTP(ctx, w, r)
+	}
+}
+
+func BenchmarkTreeFirstRoute(b *testing.B) {
+	benchServe(b, New(benchRoutes(100)), "GET", "/v1/resource0")
+}
+
+func BenchmarkLinearFirstRoute(b *testing.B) {
+	benchServe(b, newLinear(benchRoutes(100)), "GET", "/v1/resource0")
+}
+
+func BenchmarkTreeLastRoute(b *testing.B) {
+	benchServe(b, New(benchRoutes(100)), "GET", "/v1/resource99/123")
+}
+
+func BenchmarkLinearLastRoute(b *testing.B) {
+	benchServe(b, newLinear(benchRoutes(100)), "GET", "/v1/resource99/123")
+}
+
+func BenchmarkTreeLastRegexpRoute(b *testing.B) {
+	benchServe(b, New(benchRoutes(100)), "PUT", "/v1/resource99/123/items/abc")
+}
+
+func BenchmarkLinearLastRegexpRoute(b *testing.B) {
+	benchServe(b, newLinear(benchRoutes(100)), "PUT", "/v1/resource99/123/items/abc")
+}
+
+func BenchmarkTreeNotFound(b *testing.B) {
+	benchServe(b, New(benchRoutes(100)), "GET", "/v2/does/not/exist")
+}
+
+func BenchmarkLinearNotFound(b *testing.B) {
+	benchServe(b, newLinear(benchRoutes(100)), "GET", "/v2/does/not/exist")
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// template is path pattern of the named route, used to build URL path.
+type template struct {
+	// parts contains literal path chunks and arguments in order they are
+	// declared. Literal parts have empty name.
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	name    string
+	rx      *regexp.Regexp
+}
+
+// newTemplate parse given path pattern. Only patterns that outside of
+// argument definitions are not using regular expression syntax, other than
+// dots and escaped characters, can be used to build URL path.
+func newTemplate(pattern string) (*template, error) {
+	var t template
+	var end int
+	for _, loc := range placeholder.FindAllStringIndex(pattern, -1) {
+		if err := t.addLiteral(pattern[end:loc[0]]); err != nil {
+			return nil, err
+		}
+		end = loc[1]
+
+		part := templatePart{name: pattern[loc[0]+1 : loc[1]-1]}
+		rx := `[^/]+`
+		if chunks := strings.SplitN(part.name, ":", 2); len(chunks) == 2 {
+			part.name, rx = chunks[0], chunks[1]
+		}
+		var err error
+		if part.rx, err = regexp.Compile(`^(?:` + rx + `)$`); err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, part)
+	}
+	if err := t.addLiteral(pattern[end:]); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (t *template) addLiteral(s string) error {
+	literal, ok := plainText(s)
+	if !ok {
+		return fmt.Errorf("%q is not a plain text", s)
+	}
+	if literal != "" {
+		t.parts = append(t.parts, templatePart{literal: literal})
+	}
+	return nil
+}
+
+// plainText return text matched by given regular expression, if it's
+// matching only one string. Unescaped dot is considered to be a dot
+// character, as it's commonly used this way in path patterns.
+func plainText(rx string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(rx); i++ {
+		c := rx[i]
+		switch {
+		case c == '\\' && i+1 < len(rx) && regexp.QuoteMeta(rx[i+1:i+2]) != rx[i+1:i+2]:
+			i++
+			b.WriteByte(rx[i])
+		case c == '.' || regexp.QuoteMeta(string(c)) == string(c):
+			b.WriteByte(c)
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// build return URL path with all arguments replaced by given values.
+func (t *template) build(values map[string]string) (string, error) {
+	for name := range values {
+		if !t.has(name) {
+			return "", fmt.Errorf("unknown %q argument", name)
+		}
+	}
+
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.name == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		value, ok := values[part.name]
+		if !ok {
+			return "", fmt.Errorf("missing %q argument", part.name)
+		}
+		if !part.rx.MatchString(value) {
+			return "", fmt.Errorf("invalid %q argument: %q does not match %q",
+				part.name, value, part.rx.String())
+		}
+
+		chunks := strings.Split(value, "/")
+		for i, c := range chunks {
+			chunks[i] = url.PathEscape(c)
+		}
+		b.WriteString(strings.Join(chunks, "/"))
+	}
+	return b.String(), nil
+}
+
+// String return path template in {name} notation, without argument regular
+// expressions.
+func (t *template) String() string {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.name == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		b.WriteString("{" + part.name + "}")
+	}
+	return b.String()
+}
+
+func (t *template) has(name string) bool {
+	for _, part := range t.parts {
+		if part.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// URL return path of the route with given name, build using given list of
+// argument name and value pairs. Every value must match regular expression
+// defined for the argument.
+//
+//	rt.URL("user-details", "id", "123")
+func (rt *Router) URL(name string, pairs ...string) (string, error) {
+	t, ok := rt.templates[name]
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("invalid args list: pairs are not even")
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+	path, err := t.build(values)
+	if err != nil {
+		return "", fmt.Errorf("cannot build %q route URL: %s", name, err)
+	}
+	return path, nil
+}
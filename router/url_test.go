@@ -0,0 +1,58 @@
+package router
+
+import "testing"
+
+func TestURL(t *testing.T) {
+	rt := New(Routes{
+		With(Route{Methods: "GET", Path: `/users`}, Name("users")),
+		With(Route{Methods: "GET", Path: `/users/{id:\d+}`}, Name("user")),
+		With(Route{Methods: "GET", Path: `/v1.0/users`}, Name("v1-users")),
+		With(Route{Methods: "GET", Path: `/f/{x}.json`}, Name("json")),
+		With(Route{Methods: "GET", Path: `/f/{x}\.xml`}, Name("xml")),
+		Group(`/accounts/{account}`, Routes{
+			With(Route{Methods: "GET", Path: `/files/{path:.+}`}, Name("file")),
+		}),
+	})
+
+	var testCases = []struct {
+		name    string
+		pairs   []string
+		want    string
+		wantErr bool
+	}{
+		{"users", nil, "/users", false},
+		{"user", []string{"id", "42"}, "/users/42", false},
+		{"user", []string{"id", "foo"}, "", true},
+		{"user", []string{"id", "42x"}, "", true},
+		{"user", nil, "", true},
+		{"user", []string{"id", "42", "name", "bob"}, "", true},
+		{"user", []string{"id"}, "", true},
+		{"file", []string{"account", "a b", "path", "x/y z"}, "/accounts/a%20b/files/x/y%20z", false},
+		{"file", []string{"account", "a/b", "path", "x"}, "", true},
+		{"v1-users", nil, "/v1.0/users", false},
+		{"json", []string{"x", "a"}, "/f/a.json", false},
+		{"xml", []string{"x", "a"}, "/f/a.xml", false},
+		{"does-not-exist", nil, "", true},
+	}
+
+	for i, tc := range testCases {
+		got, err := rt.URL(tc.name, tc.pairs...)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%d: want error %v, got %v", i, tc.wantErr, err)
+		}
+		if got != tc.want {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestURLInvalidTemplate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("want panic")
+		}
+	}()
+	New(Routes{
+		With(Route{Methods: "GET", Path: `/files/.*`}, Name("files")),
+	})
+}
@@ -0,0 +1,271 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Validate inspect given routes and return list of found problems:
+//
+// - routes that are unreachable, because for every method they accept,
+// route declared before is matching all their paths,
+// - routes that are ambiguous, because they are partially overlapping with
+// route declared before, without one being more specific than the other,
+// - argument names used more than once within the same path,
+// - argument regular expressions containing capture groups, that are
+// shifting positions of values returned by ByIndex and can break ByName.
+//
+// Routes with host or header conditions are not considered to shadow routes
+// with different conditions.
+//
+// Deciding if one regular expression is matching all strings of another one
+// is not always possible, so only shadowing by plain, {name} and match-all
+// (.*) segments is reported.
+//
+// Because routes are matched in declaration order, it is recommended to call
+// Validate in application tests.
+func Validate(routes Routes) []error {
+	var errs []error
+
+	var infos []*routeInfo
+	for i, r := range flatten(routes) {
+		info := newRouteInfo(i, r)
+		errs = append(errs, info.check()...)
+
+		remaining := copyMethods(info.methods)
+		for _, prev := range infos {
+			if !prev.sharesMethods(info) || !prev.sharesConditions(info) {
+				continue
+			}
+			switch {
+			case covers(prev.segments, info.segments):
+				prev.removeFrom(remaining)
+				if len(remaining) == 0 {
+					errs = append(errs, fmt.Errorf("route %s is unreachable: shadowed by route %s", info, prev))
+				}
+			case overlaps(prev.segments, info.segments) && !covers(info.segments, prev.segments):
+				errs = append(errs, fmt.Errorf("route %s is ambiguous: overlaps with route %s", info, prev))
+			}
+			if len(remaining) == 0 {
+				break
+			}
+		}
+		infos = append(infos, info)
+	}
+	return errs
+}
+
+type routeInfo struct {
+	index   int
+	route   route
+	methods map[string]bool
+
+	// segments contains path segments with argument names removed, so that
+	// {a} and {b} are considered the same.
+	segments []string
+}
+
+func newRouteInfo(index int, r route) *routeInfo {
+	info := &routeInfo{
+		index:   index,
+		route:   r,
+		methods: make(map[string]bool),
+	}
+	for _, m := range strings.Split(r.Methods, ",") {
+		info.methods[m] = true
+	}
+	for _, seg := range splitPattern(r.Path) {
+		seg = placeholder.ReplaceAllStringFunc(seg, func(s string) string {
+			chunks := strings.SplitN(s[1:len(s)-1], ":", 2)
+			if len(chunks) == 1 {
+				return "{}"
+			}
+			return "{:" + chunks[1] + "}"
+		})
+		info.segments = append(info.segments, seg)
+	}
+	return info
+}
+
+func (info *routeInfo) String() string {
+	return fmt.Sprintf("#%d %s %q", info.index, info.route.Methods, info.route.Path)
+}
+
+// check return problems with path pattern of the route.
+func (info *routeInfo) check() []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, s := range placeholder.FindAllString(info.route.Path, -1) {
+		chunks := strings.SplitN(s[1:len(s)-1], ":", 2)
+		if seen[chunks[0]] {
+			errs = append(errs, fmt.Errorf("route %s: duplicated %q argument name", info, chunks[0]))
+		}
+		seen[chunks[0]] = true
+
+		if len(chunks) == 1 {
+			continue
+		}
+		rx, err := regexp.Compile(chunks[1])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("route %s: invalid %q argument: %s", info, chunks[0], err))
+			continue
+		}
+		if rx.NumSubexp() != 0 {
+			errs = append(errs, fmt.Errorf("route %s: %q argument regular expression contains capture group, use (?:...) instead", info, chunks[0]))
+		}
+	}
+	return errs
+}
+
+func (info *routeInfo) sharesMethods(other *routeInfo) bool {
+	if info.methods[AnyMethod] || other.methods[AnyMethod] {
+		return true
+	}
+	for m := range other.methods {
+		if info.methods[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesConditions return true if every request matching other route
+// conditions is also matching conditions of this route, other than method
+// and path.
+func (info *routeInfo) sharesConditions(other *routeInfo) bool {
+	if info.route.host != "" && info.route.host != other.route.host {
+		return false
+	}
+	for name, value := range info.route.headers {
+		if other.route.headers[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// removeFrom delete from given set all methods accepted by the route.
+func (info *routeInfo) removeFrom(methods map[string]bool) {
+	if info.methods[AnyMethod] {
+		for m := range methods {
+			delete(methods, m)
+		}
+		return
+	}
+	for m := range info.methods {
+		delete(methods, m)
+	}
+}
+
+func copyMethods(methods map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(methods))
+	for m := range methods {
+		c[m] = true
+	}
+	return c
+}
+
+// covers return true if path pattern a is matching every path that b is
+// matching. Returns false if that cannot be decided.
+func covers(a, b []string) bool {
+	for i, sa := range a {
+		if i >= len(b) {
+			return false
+		}
+		if strings.Join(a[i:], "/") == strings.Join(b[i:], "/") {
+			return true
+		}
+		sb := b[i]
+
+		switch {
+		case isStatic(sa):
+			if !isStatic(sb) || sa != sb {
+				return false
+			}
+		case isParam(sa):
+			if isStatic(sb) && sb == "" {
+				return false
+			}
+			if !isStatic(sb) && !isParam(sb) {
+				return false
+			}
+		default:
+			if matchesAll(strings.Join(a[i:], "/")) {
+				return true
+			}
+			return matchesStatic(a[i:], b[i:])
+		}
+	}
+	return len(a) == len(b)
+}
+
+// overlaps return true if there is at least one path, that both a and b path
+// patterns are matching. Returns false if that cannot be decided.
+func overlaps(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		sa, sb := a[i], b[i]
+		switch {
+		case !isStatic(sa) && !isParam(sa):
+			return matchesAll(strings.Join(a[i:], "/")) || matchesStatic(a[i:], b[i:])
+		case !isStatic(sb) && !isParam(sb):
+			return matchesAll(strings.Join(b[i:], "/")) || matchesStatic(b[i:], a[i:])
+		case isStatic(sa) && isStatic(sb):
+			if sa != sb {
+				return false
+			}
+		case isStatic(sa):
+			if sa == "" {
+				return false
+			}
+		case isStatic(sb):
+			if sb == "" {
+				return false
+			}
+		}
+	}
+	return len(a) == len(b)
+}
+
+func isParam(seg string) bool {
+	return seg == "{}"
+}
+
+// matchesStatic return true if b segments are plain text matching regular
+// expression defined by a segments.
+func matchesStatic(a, b []string) bool {
+	path := strings.Join(b, "/")
+	if !isStatic(path) {
+		return false
+	}
+	rx, _, err := compile(strings.Join(a, "/"))
+	return err == nil && rx.MatchString(path)
+}
+
+// matchesAll return true if given path pattern is a regular expression
+// matching any string, like .* or {name:.*}
+func matchesAll(pattern string) bool {
+	rx, _, err := compile(pattern)
+	if err != nil {
+		return false
+	}
+	re, err := syntax.Parse(rx.String(), syntax.Perl)
+	if err != nil {
+		return false
+	}
+	re = re.Simplify()
+	for re.Op == syntax.OpConcat || re.Op == syntax.OpCapture {
+		var subs []*syntax.Regexp
+		for _, sub := range re.Sub {
+			if sub.Op != syntax.OpBeginText && sub.Op != syntax.OpEndText && sub.Op != syntax.OpEmptyMatch {
+				subs = append(subs, sub)
+			}
+		}
+		if len(subs) != 1 {
+			return false
+		}
+		re = subs[0]
+	}
+	return re.Op == syntax.OpStar && (re.Sub[0].Op == syntax.OpAnyCharNotNL || re.Sub[0].Op == syntax.OpAnyChar)
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	var testCases = []struct {
+		routes   Routes
+		wantErrs []string
+	}{
+		{
+			routes: Routes{
+				{Methods: "GET", Path: `/users`},
+				{Methods: "GET", Path: `/users/me`},
+				{Methods: "GET,PUT", Path: `/users/{id}`},
+				{Methods: "GET", Path: `/users/{id}/accounts/{account:\d+}`},
+			},
+			wantErrs: nil,
+		},
+		{
+			routes: Routes{
+				{Methods: AnyMethod, Path: `.*`},
+				{Methods: "GET", Path: `/users`},
+			},
+			wantErrs: []string{`route #1 GET "/users" is unreachable: shadowed by route #0 * ".*"`},
+		},
+		{
+			routes: Routes{
+				{Methods: "GET", Path: `/users/{id}`},
+				{Methods: "POST", Path: `/users/{name}`},
+				{Methods: "GET,POST", Path: `/users/me`},
+			},
+			wantErrs: []string{`route #2 GET,POST "/users/me" is unreachable: shadowed by route #1 POST "/users/{name}"`},
+		},
+		{
+			routes: Routes{
+				{Methods: "GET", Path: `/users/{id:\d+}`},
+				{Methods: "GET", Path: `/users/{user:\d+}`},
+				{Methods: "GET", Path: `/users/42`},
+				{Methods: "GET", Path: `/users/{x:[a-z]+}`},
+			},
+			wantErrs: []string{
+				`route #1 GET "/users/{user:\\d+}" is unreachable: shadowed by route #0 GET "/users/{id:\\d+}"`,
+				`route #2 GET "/users/42" is unreachable: shadowed by route #0 GET "/users/{id:\\d+}"`,
+			},
+		},
+		{
+			routes: Routes{
+				{Methods: "GET", Path: `/{a}/b`},
+				{Methods: "GET", Path: `/a/{b}`},
+				{Methods: "POST", Path: `/a/{b}/c`},
+			},
+			wantErrs: []string{`route #1 GET "/a/{b}" is ambiguous: overlaps with route #0 GET "/{a}/b"`},
+		},
+		{
+			routes: Routes{
+				{Methods: "GET", Path: `/{id}/x/{id}`},
+				{Methods: "GET", Path: `/{name:(foo|bar)}`},
+				{Methods: "GET", Path: `/{name:(?:foo|bar)}/x`},
+			},
+			wantErrs: []string{
+				`route #0 GET "/{id}/x/{id}": duplicated "id" argument name`,
+				`route #1 GET "/{name:(foo|bar)}": "name" argument regular expression contains capture group, use (?:...) instead`,
+			},
+		},
+		{
+			routes: Routes{
+				Group(`/v1`, Routes{
+					{Methods: "GET", Path: `/{path:.*}`},
+				}),
+				{Methods: "GET", Path: `/v1/users`},
+				{Methods: "GET", Path: `/v2/users`},
+			},
+			wantErrs: []string{`route #1 GET "/v1/users" is unreachable: shadowed by route #0 GET "/v1/{path:.*}"`},
+		},
+		{
+			routes: Routes{
+				With(Route{Methods: "GET", Path: `/users`}, Host(`partner.example.com`)),
+				With(Route{Methods: "GET", Path: `/users`}, Headers(map[string]string{"X-Version": "2"})),
+				{Methods: "GET", Path: `/users`},
+				With(Route{Methods: "GET", Path: `/users`}, Host(`admin.example.com`)),
+			},
+			wantErrs: []string{`route #3 GET "/users" is unreachable: shadowed by route #2 GET "/users"`},
+		},
+	}
+
+	for i, tc := range testCases {
+		var got []string
+		for _, err := range Validate(tc.routes) {
+			got = append(got, err.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.wantErrs, "\n") {
+			t.Errorf("%d: want errors\n%s\ngot\n%s", i, strings.Join(tc.wantErrs, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressMinSize is the minimum size of response body in bytes, that
+// Compress middleware is compressing. Smaller responses are send as they are,
+// because compression would not reduce their size significantly.
+var CompressMinSize = 1024
+
+// Compress wraps given handler to compress response body using gzip or
+// deflate (zlib format) encoding, if client accepts any of them. Compression
+// is skipped for responses smaller than CompressMinSize, responses that
+// already declare encoding, partial content responses and content types that
+// are compressed on their own, like images or archives.
+//
+// Strong entity tag of compressed response gets encoding suffix, so that
+// representations with different encodings have different tags. Not modified
+// response gets the suffix as well, if client is revalidating compressed
+// representation.
+//
+// Response is buffered until CompressMinSize bytes is written. Flushing the
+// response, like streaming helpers do, ends buffering, so that streamed data
+// reaches the client as soon as possible.
+//
+// Use router.StdMiddleware to use it as router middleware:
+//
+//	rt := router.New(routes, router.Use(router.StdMiddleware(web.Compress)))
+func Compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == "HEAD" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, ifNoneMatch: r.Header.Get("If-None-Match")}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding return the best of supported encodings, that is accepted
+// according to given Accept-Encoding header value. Empty string is returned
+// if none is accepted. Wildcard applies only to encodings not listed
+// explicitly.
+func acceptedEncoding(accept string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		chunks := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(chunks[0]))
+		q := 1.0
+		for _, param := range chunks[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		qualities[name] = q
+	}
+
+	var (
+		best    string
+		quality float64
+	)
+	// gzip is preferred if quality is the same
+	for _, name := range []string{"gzip", "deflate"} {
+		q, ok := qualities[name]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > quality {
+			best, quality = name, q
+		}
+	}
+	return best
+}
+
+var (
+	gzipPool = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	// deflate content coding is zlib format, not raw deflate
+	zlibPool = sync.Pool{New: func() interface{} { return zlib.NewWriter(nil) }}
+)
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressWriter is buffering response until it's big enough to decide if it
+// should be compressed.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	ifNoneMatch string
+	code        int
+	buf         bytes.Buffer
+	decided     bool
+	cw          compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	n, _ := w.buf.Write(b)
+	if w.buf.Len() >= CompressMinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Flush send buffered data to the client. It ends buffering, so further
+// writes are compressed if response can be compressed.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.cw != nil {
+		if err := w.cw.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide write response header and buffered data, compressed if it's
+// possible and if compress is true.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	header := w.Header()
+	etag := header.Get("ETag")
+	strong := len(etag) > 1 && etag[0] == '"' && etag[len(etag)-1] == '"'
+	if w.code == http.StatusNotModified && strong && hasETag(w.ifNoneMatch, encodedETag(etag, w.encoding)) {
+		// not modified response must carry the tag of the representation
+		// client has, which is the compressed one
+		header.Set("ETag", encodedETag(etag, w.encoding))
+	}
+	if compress && w.compressible() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// compressed representation is not byte to byte equal, so it needs
+		// its own strong tag
+		if strong {
+			header.Set("ETag", encodedETag(etag, w.encoding))
+		}
+		if w.encoding == "gzip" {
+			w.cw = gzipPool.Get().(*gzip.Writer)
+		} else {
+			w.cw = zlibPool.Get().(*zlib.Writer)
+		}
+		w.cw.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// encodedETag return strong entity tag of the representation with given
+// content encoding, for example "abc-gzip" for "abc" tag.
+func encodedETag(tag, encoding string) string {
+	return tag[:len(tag)-1] + "-" + encoding + `"`
+}
+
+// hasETag return true if given If-None-Match header value contains given
+// entity tag, compared weakly.
+func hasETag(list, tag string) bool {
+	for _, t := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible return true if response can be compressed.
+func (w *compressWriter) compressible() bool {
+	switch {
+	case w.code < 200, w.code == http.StatusNoContent, w.code == http.StatusNotModified,
+		w.code == http.StatusPartialContent:
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	return !isCompressed(header.Get("Content-Type"))
+}
+
+// isCompressed return true if content of given type is already compressed.
+func isCompressed(contentType string) bool {
+	mediatype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediatype == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediatype, "image/"),
+		strings.HasPrefix(mediatype, "video/"),
+		strings.HasPrefix(mediatype, "audio/"),
+		strings.HasPrefix(mediatype, "font/woff"):
+		return true
+	}
+	switch mediatype {
+	case "application/zip", "application/gzip", "application/x-gzip",
+		"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/zstd":
+		return true
+	}
+	return false
+}
+
+// close write response that was not big enough to be compressed and finish
+// compressed stream.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.code == 0 && w.buf.Len() == 0 {
+			// handler did not write anything, let the server respond
+			return
+		}
+		_ = w.decide(false)
+	}
+	if w.cw == nil {
+		return
+	}
+	_ = w.cw.Close()
+	switch c := w.cw.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(c)
+	case *zlib.Writer:
+		zlibPool.Put(c)
+	}
+	w.cw = nil
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	testcases := []struct {
+		accept   string
+		expected string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"br, *", "gzip"},
+		{"gzip;q=0, *", "deflate"},
+		{"gzip;q=0, deflate;q=0, *", ""},
+		{"*, gzip;q=0.5", "deflate"},
+		{"*;q=0, deflate", "deflate"},
+		{"GZIP", "gzip"},
+	}
+	for i, tc := range testcases {
+		if got := acceptedEncoding(tc.accept); got != tc.expected {
+			t.Errorf("%d: expected %q, got %q", i, tc.expected, got)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"name": "john doe"}`, 100)
+
+	testcases := []struct {
+		accept      string
+		contentType string
+		body        string
+		code        int
+		encoding    string
+	}{
+		{"gzip", "application/json", large, http.StatusOK, "gzip"},
+		{"deflate", "application/json", large, http.StatusCreated, "deflate"},
+		{"", "application/json", large, http.StatusOK, ""},
+		{"gzip", "application/json", "{}", http.StatusOK, ""},
+		{"gzip", "image/png", large, http.StatusOK, ""},
+		{"gzip", "image/svg+xml", large, http.StatusOK, "gzip"},
+		{"gzip", "application/zip", large, http.StatusOK, ""},
+		{"gzip", "", large, http.StatusOK, "gzip"},
+		{"gzip", "application/json", "", http.StatusNoContent, ""},
+	}
+
+	for i, tc := range testcases {
+		h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.contentType != "" {
+				w.Header().Set("Content-Type", tc.contentType)
+			}
+			w.Header().Set("ETag", `"abc"`)
+			w.WriteHeader(tc.code)
+			// write in small chunks to test buffering
+			for s := tc.body; s != ""; {
+				n := 100
+				if n > len(s) {
+					n = len(s)
+				}
+				_, _ = io.WriteString(w, s[:n])
+				s = s[n:]
+			}
+		}))
+		r, _ := http.NewRequest("GET", "/", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept-Encoding", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tc.code {
+			t.Errorf("%d: expected %d, got %d", i, tc.code, w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tc.encoding {
+			t.Errorf("%d: expected %q encoding, got %q", i, tc.encoding, got)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%d: expected Vary header, got %q", i, w.Header().Get("Vary"))
+		}
+		etag := `"abc"`
+		if tc.encoding != "" {
+			etag = `"abc-` + tc.encoding + `"`
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("%d: expected %s ETag, got %s", i, etag, got)
+		}
+		if body := decompress(t, tc.encoding, w.Body); body != tc.body {
+			t.Errorf("%d: expected %d bytes body, got %d", i, len(tc.body), len(body))
+		}
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = StreamNDJSON(w, r, SliceIterator([]int{1, 2, 3}), http.StatusOK)
+	}))
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Content-Type") != "application/x-ndjson; charset=UTF-8" {
+		t.Errorf("invalid content type: %q", w.Header().Get("Content-Type"))
+	}
+	if !w.Flushed {
+		t.Error("expected response to be flushed")
+	}
+	if body := decompress(t, "gzip", w.Body); body != "1\n2\n3\n" {
+		t.Errorf("unexpected body: %q", body)
+	}
+}
+
+func TestCompressNotModified(t *testing.T) {
+	testcases := []struct {
+		content  interface{}
+		encoding string
+	}{
+		{strings.Repeat("john doe", 200), "gzip"},
+		{"john doe", ""},
+	}
+
+	for i, tc := range testcases {
+		h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			CondJSONResp(w, r, tc.content, http.StatusOK, time.Time{})
+		}))
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Content-Encoding"); got != tc.encoding {
+			t.Fatalf("%d: expected %q encoding, got %q", i, tc.encoding, got)
+		}
+		etag := w.Header().Get("ETag")
+		if tc.encoding != "" && !strings.HasSuffix(etag, `-`+tc.encoding+`"`) {
+			t.Fatalf("%d: expected encoded ETag, got %s", i, etag)
+		}
+
+		r.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%d: expected %d, got %d", i, http.StatusNotModified, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("%d: expected %s ETag, got %s", i, etag, got)
+		}
+	}
+}
+
+func decompress(t *testing.T, encoding string, body *bytes.Buffer) string {
+	var r io.Reader = body
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatalf("cannot read gzip: %s", err)
+		}
+		r = gr
+	case "deflate":
+		zr, err := zlib.NewReader(body)
+		if err != nil {
+			t.Fatalf("cannot read zlib: %s", err)
+		}
+		r = zr
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("cannot read body: %s", err)
+	}
+	return string(b)
+}
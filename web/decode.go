@@ -0,0 +1,361 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/optiopay/x/apierr"
+)
+
+// MaxJSONBodySize is the maximum size of request body in bytes, that
+// DecodeJSON accepts.
+var MaxJSONBodySize int64 = 1 << 20
+
+// DecodeJSON decode JSON serialized request body into given destination.
+// Request must declare application/json (or any +json) content type, body
+// must not be bigger than MaxJSONBodySize and must not contain fields that
+// destination structure does not declare.
+//
+// If body cannot be decoded, errors are returned, that can be send to the
+// client as they are. Validation errors have Param set to JSON pointer of
+// invalid value, for example "/address/city". Use ErrStatus to get response
+// code matching returned errors:
+//
+//	var input struct {
+//		Name string `json:"name"`
+//	}
+//	if errs := web.DecodeJSON(r, &input); errs != nil {
+//		web.JSONErr(w, errs, web.ErrStatus(errs))
+//		return
+//	}
+func DecodeJSON(r *http.Request, dst interface{}) apierr.Errors {
+	if !isJSON(r.Header.Get("Content-Type")) {
+		return apierr.Errors{}.WithUnsupportedMediaType("content type has to be application/json")
+	}
+	if r.Body == nil {
+		return apierr.Errors{}.WithMalformedJSON("request body is empty")
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxJSONBodySize+1))
+	if err != nil {
+		return apierr.Errors{}.WithMalformedJSON(fmt.Sprintf("cannot read body: %s", err))
+	}
+	if int64(len(b)) > MaxJSONBodySize {
+		return apierr.Errors{}.WithRequestTooLarge(fmt.Sprintf("body must not be bigger than %d bytes", MaxJSONBodySize))
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeErrors(err, b, reflect.TypeOf(dst))
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return apierr.Errors{}.WithMalformedJSON("body must contain single JSON value")
+	}
+	return nil
+}
+
+func isJSON(contentType string) bool {
+	mediatype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediatype == "application/json" || (strings.HasPrefix(mediatype, "application/") && strings.HasSuffix(mediatype, "+json"))
+}
+
+// decodeErrors return API errors describing given JSON decoder error, that
+// occurred while decoding given body into value of given type.
+func decodeErrors(err error, body []byte, t reflect.Type) apierr.Errors {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		param := pointer(e.Field)
+		if path, ok := valuePath(body, e.Offset); ok {
+			// decoder is joining path with dots, even when they are
+			// part of the map key
+			param = joinPointer(path)
+		}
+		kind := kindName(e.Type)
+		message := fmt.Sprintf("%q has to be %s", param, kind)
+		switch kind {
+		case "string":
+			return apierr.Errors{}.WithNotString(param, message)
+		case "boolean":
+			return apierr.Errors{}.WithNotBoolean(param, message)
+		case "integer":
+			return apierr.Errors{}.WithNotInteger(param, message)
+		case "number":
+			return apierr.Errors{}.WithNotNumber(param, message)
+		case "list":
+			return apierr.Errors{}.WithNotList(param, message)
+		default:
+			return apierr.Errors{}.WithNotObject(param, message)
+		}
+	case *json.SyntaxError:
+		return apierr.Errors{}.WithMalformedJSON(fmt.Sprintf("invalid JSON at offset %d: %s", e.Offset, e))
+	}
+
+	if err == io.EOF {
+		return apierr.Errors{}.WithMalformedJSON("request body is empty")
+	}
+	// decoder does not provide dedicated error type for unknown fields, nor
+	// the path of the object containing it
+	if name := strings.TrimPrefix(err.Error(), "json: unknown field "); name != err.Error() {
+		name = strings.Trim(name, `"`)
+		path, ok := unknownField(body, t)
+		if !ok || path[len(path)-1] != name {
+			return apierr.Errors{}.WithNoSuchFild("", fmt.Sprintf("unknown field %q", name))
+		}
+		return apierr.Errors{}.WithNoSuchFild(joinPointer(path), "")
+	}
+	return apierr.Errors{}.WithMalformedJSON(err.Error())
+}
+
+// unknownField return path to the first field of given JSON document, that
+// is not declared by the destination of given type. False is returned if
+// there is no such field or if document cannot be walked, for example
+// because part of it is decoded by custom unmarshaler.
+func unknownField(doc []byte, t reflect.Type) ([]string, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil, false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		var obj []objectMember
+		if !decodeObject(doc, &obj) {
+			return nil, false
+		}
+		for _, m := range obj {
+			var ft reflect.Type
+			if t.Kind() == reflect.Map {
+				ft = t.Elem()
+			} else if ft = structField(t, m.name); ft == nil {
+				return []string{m.name}, true
+			}
+			if path, ok := unknownField(m.value, ft); ok {
+				return append([]string{m.name}, path...), true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		var list []json.RawMessage
+		if json.Unmarshal(doc, &list) != nil {
+			return nil, false
+		}
+		for i, item := range list {
+			if path, ok := unknownField(item, t.Elem()); ok {
+				return append([]string{strconv.Itoa(i)}, path...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// valuePath return path to the value of given JSON document, which first
+// token ends at given offset, as reported by JSON decoder in type errors.
+// False is returned if there is no such value.
+func valuePath(doc []byte, offset int64) ([]string, bool) {
+	type level struct {
+		object  bool
+		wantKey bool
+		name    string
+		index   int
+	}
+	var stack []*level
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if n := len(stack); n != 0 {
+			switch top := stack[n-1]; {
+			case top.object && top.wantKey:
+				top.name, _ = tok.(string)
+				top.wantKey = false
+				continue
+			case top.object:
+				top.wantKey = true
+			default:
+				top.name = strconv.Itoa(top.index)
+				top.index++
+			}
+		}
+
+		if dec.InputOffset() == offset {
+			path := make([]string, len(stack))
+			for i, l := range stack {
+				path[i] = l.name
+			}
+			return path, true
+		}
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, &level{object: d == '{', wantKey: true})
+		}
+	}
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+type objectMember struct {
+	name  string
+	value json.RawMessage
+}
+
+// decodeObject decode members of JSON object, keeping their order. False is
+// returned if document is not an object.
+func decodeObject(doc []byte, obj *[]objectMember) bool {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		name, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return false
+		}
+		*obj = append(*obj, objectMember{name: name, value: value})
+	}
+	return true
+}
+
+// structField return type of the structure field, that JSON object member
+// with given name is decoded into. Like JSON decoder, exact name match is
+// preferred over case insensitive one. Nil is returned if there is no such
+// field.
+func structField(t reflect.Type, name string) reflect.Type {
+	var fold reflect.Type
+	for _, f := range jsonFields(t) {
+		if f.Name == name {
+			return f.Type
+		}
+		if fold == nil && strings.EqualFold(f.Name, name) {
+			fold = f.Type
+		}
+	}
+	return fold
+}
+
+// jsonFields return fields of given structure, including fields of embedded
+// structures, with names replaced by JSON names.
+func jsonFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name != "" {
+			f.Name = name
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// pointer return JSON pointer of the value described by dot separated path,
+// as reported by JSON decoder.
+func pointer(path string) string {
+	if path == "" {
+		return ""
+	}
+	return joinPointer(strings.Split(path, "."))
+}
+
+// joinPointer return JSON pointer of the value described by given list of
+// object member names and list indexes.
+func joinPointer(path []string) string {
+	var b strings.Builder
+	for _, name := range path {
+		name = strings.Replace(name, "~", "~0", -1)
+		name = strings.Replace(name, "/", "~1", -1)
+		b.WriteString("/")
+		b.WriteString(name)
+	}
+	return b.String()
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	default:
+		return "object"
+	}
+}
+
+// ErrStatus return HTTP response code matching given errors. Request errors
+// with well known codes map to dedicated status codes, all other errors are
+// considered bad request.
+func ErrStatus(errs apierr.Errors) int {
+	for _, e := range errs {
+		switch e.Type {
+		case "server_error":
+			return http.StatusInternalServerError
+		case "request_error":
+			switch e.Code {
+			case "not_found":
+				return http.StatusNotFound
+			case "unauthorized":
+				return http.StatusUnauthorized
+			case "forbidden":
+				return http.StatusForbidden
+			case "rate_limit":
+				return http.StatusTooManyRequests
+			case "precondition_failed":
+				return http.StatusPreconditionFailed
+			case "not_acceptable":
+				return http.StatusNotAcceptable
+			case "unsupported_media_type":
+				return http.StatusUnsupportedMediaType
+			case "request_too_large":
+				return http.StatusRequestEntityTooLarge
+			}
+		}
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/optiopay/x/apierr"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+	type input struct {
+		Name    string         `json:"name"`
+		Age     int            `json:"age"`
+		Score   float64        `json:"score"`
+		Active  bool           `json:"active"`
+		Tags    []string       `json:"tags"`
+		Address address        `json:"address"`
+		Past    []address      `json:"past"`
+		Limits  map[string]int `json:"limits"`
+	}
+
+	testcases := []struct {
+		contentType string
+		body        string
+		errs        apierr.Errors
+		status      int
+	}{
+		{"application/json", `{"name": "bob", "age": 42, "address": {"city": "Berlin"}}`, nil, 0},
+		{"application/json; charset=utf-8", `{"tags": ["a"]}`, nil, 0},
+		{"application/merge-patch+json", `{}`, nil, 0},
+		{"text/plain", `{}`, apierr.Errors{}.WithUnsupportedMediaType(""), http.StatusUnsupportedMediaType},
+		{"", `{}`, apierr.Errors{}.WithUnsupportedMediaType(""), http.StatusUnsupportedMediaType},
+		{"application/json", `{"name": "` + strings.Repeat("x", 100) + `"}`, apierr.Errors{}.WithRequestTooLarge(""), http.StatusRequestEntityTooLarge},
+		{"application/json", ``, apierr.Errors{}.WithMalformedJSON(""), http.StatusBadRequest},
+		{"application/json", `{"name": `, apierr.Errors{}.WithMalformedJSON(""), http.StatusBadRequest},
+		{"application/json", `{"name": "a"} {}`, apierr.Errors{}.WithMalformedJSON(""), http.StatusBadRequest},
+		{"application/json", `{"name": 1}`, apierr.Errors{}.WithNotString("/name", ""), http.StatusBadRequest},
+		{"application/json", `{"age": "1"}`, apierr.Errors{}.WithNotInteger("/age", ""), http.StatusBadRequest},
+		{"application/json", `{"age": 1.5}`, apierr.Errors{}.WithNotInteger("/age", ""), http.StatusBadRequest},
+		{"application/json", `{"score": true}`, apierr.Errors{}.WithNotNumber("/score", ""), http.StatusBadRequest},
+		{"application/json", `{"active": 1}`, apierr.Errors{}.WithNotBoolean("/active", ""), http.StatusBadRequest},
+		{"application/json", `{"tags": "a"}`, apierr.Errors{}.WithNotList("/tags", ""), http.StatusBadRequest},
+		{"application/json", `{"address": []}`, apierr.Errors{}.WithNotObject("/address", ""), http.StatusBadRequest},
+		{"application/json", `{"address": {"city": 1}}`, apierr.Errors{}.WithNotString("/address/city", ""), http.StatusBadRequest},
+		{"application/json", `{"past": [{"city": "a"}, {"city": 1}]}`, apierr.Errors{}.WithNotString("/past/1/city", ""), http.StatusBadRequest},
+		{"application/json", `{"limits": {"a": 1, "a.b/c": "x"}}`, apierr.Errors{}.WithNotInteger("/limits/a.b~1c", ""), http.StatusBadRequest},
+		{"application/json", `"bob"`, apierr.Errors{}.WithNotObject("", ""), http.StatusBadRequest},
+		{"application/json", `{"nickname": "bobby"}`, apierr.Errors{}.WithNoSuchFild("/nickname", ""), http.StatusBadRequest},
+		{"application/json", `{"address": {"zip": 1}}`, apierr.Errors{}.WithNoSuchFild("/address/zip", ""), http.StatusBadRequest},
+		{"application/json", `{"NAME": "a", "address": {"City": "a", "a/b": 1}}`, apierr.Errors{}.WithNoSuchFild("/address/a~1b", ""), http.StatusBadRequest},
+		{"application/json", `{"past": [{"city": "a"}, {"zip": 1}]}`, apierr.Errors{}.WithNoSuchFild("/past/1/zip", ""), http.StatusBadRequest},
+	}
+
+	defer func(size int64) { MaxJSONBodySize = size }(MaxJSONBodySize)
+	MaxJSONBodySize = 80
+
+	for i, tc := range testcases {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("%d: cannot create request: %s", i, err)
+		}
+		r.Header.Set("Content-Type", tc.contentType)
+
+		var dst input
+		errs := DecodeJSON(r, &dst)
+		if len(errs) != len(tc.errs) {
+			t.Errorf("%d: expected %v, got %v", i, tc.errs, errs)
+			continue
+		}
+		for j := range errs {
+			if errs[j].Type != tc.errs[j].Type || errs[j].Code != tc.errs[j].Code || errs[j].Param != tc.errs[j].Param {
+				t.Errorf("%d: expected %v, got %v", i, tc.errs[j], errs[j])
+			}
+		}
+		if errs != nil && ErrStatus(errs) != tc.status {
+			t.Errorf("%d: expected status %d, got %d", i, tc.status, ErrStatus(errs))
+		}
+	}
+}
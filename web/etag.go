@@ -0,0 +1,142 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/log"
+)
+
+// ETag return strong entity tag of given content serialized the same way as
+// JSONResp does.
+func ETag(content interface{}) (string, error) {
+	b, err := json.MarshalIndent(content, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return etag(b), nil
+}
+
+func etag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// CondJSONResp send to given writer JSON encoded content, the same as
+// JSONResp, together with ETag and, if modified is not zero, Last-Modified
+// headers. For GET and HEAD requests successful response is replaced with
+// 304 response without body, if client has current version, as declared
+// with If-None-Match or If-Modified-Since header.
+func CondJSONResp(w http.ResponseWriter, r *http.Request, content interface{}, code int, modified time.Time) {
+	b, err := json.MarshalIndent(content, "", "\t")
+	if err != nil {
+		log.Error("cannot JSON serialize response",
+			"content", fmt.Sprintf("%T", content),
+			"error", err.Error())
+		StdErr(w, r, http.StatusInternalServerError)
+		return
+	}
+	tag := etag(b)
+
+	header := w.Header()
+	header.Set("ETag", tag)
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if code == http.StatusOK && (r.Method == "GET" || r.Method == "HEAD") && notModified(r, tag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// notModified return true if client has current version of the resource.
+func notModified(r *http.Request, tag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, tag, false)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// CheckPrecondition return true if request can modify resource with given
+// current content and modification time, according to If-Match or, if not
+// present, If-Unmodified-Since header. Current content must be the same as
+// the one send to the client with CondJSONResp, so that entity tags can be
+// compared. Content is nil if resource does not exist.
+//
+// If precondition fails, 412 response with precondition_failed error is
+// written and false returned:
+//
+//	if !web.CheckPrecondition(w, r, account, account.Updated) {
+//		return
+//	}
+//
+// Tags are compared using strong comparison, so weak tags never match. Tags
+// of representations compressed by Compress middleware match the same as tag
+// of uncompressed content.
+func CheckPrecondition(w http.ResponseWriter, r *http.Request, current interface{}, modified time.Time) bool {
+	if im := r.Header.Get("If-Match"); im != "" {
+		ok := false
+		if current != nil {
+			tag, err := ETag(current)
+			if err != nil {
+				log.Error("cannot compute ETag",
+					"content", fmt.Sprintf("%T", current),
+					"error", err.Error())
+				StdErr(w, r, http.StatusInternalServerError)
+				return false
+			}
+			ok = matchETag(im, tag, true)
+		}
+		if !ok {
+			errs := apierr.Errors{}.WithPreconditionFailed("resource was modified")
+			Err(w, r, errs, http.StatusPreconditionFailed)
+		}
+		return ok
+	}
+
+	if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ius)
+		if err == nil && modified.Truncate(time.Second).After(t) {
+			errs := apierr.Errors{}.WithPreconditionFailed("resource was modified")
+			Err(w, r, errs, http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	return true
+}
+
+// matchETag return true if given list of entity tags, as send in If-Match
+// or If-None-Match header, is "*" or contains tag of any representation of
+// the content with given strong tag. If strong is true, weak tags from the
+// list never match, otherwise weak prefix is ignored.
+func matchETag(list, tag string, strong bool) bool {
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if strings.HasPrefix(t, "W/") {
+			if strong {
+				continue
+			}
+			t = t[2:]
+		}
+		if t == tag || t == encodedETag(tag, "gzip") || t == encodedETag(tag, "deflate") {
+			return true
+		}
+	}
+	return false
+}
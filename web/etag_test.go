@@ -0,0 +1,133 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/apierr/apierrtest"
+)
+
+func TestCondJSONResp(t *testing.T) {
+	content := map[string]string{"name": "john doe"}
+	tag, err := ETag(content)
+	if err != nil {
+		t.Fatalf("cannot compute ETag: %s", err)
+	}
+	modified := time.Date(2016, 1, 2, 10, 30, 15, 500, time.UTC)
+
+	testcases := []struct {
+		method string
+		header map[string]string
+		code   int
+	}{
+		{"GET", nil, http.StatusOK},
+		{"GET", map[string]string{"If-None-Match": tag}, http.StatusNotModified},
+		{"HEAD", map[string]string{"If-None-Match": `"other", ` + tag}, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": "W/" + tag}, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": encodedETag(tag, "gzip")}, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"GET", map[string]string{"If-Modified-Since": "Sat, 02 Jan 2016 10:30:15 GMT"}, http.StatusNotModified},
+		{"GET", map[string]string{"If-Modified-Since": "Sat, 02 Jan 2016 10:30:14 GMT"}, http.StatusOK},
+		// If-None-Match takes precedence
+		{"GET", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Sat, 02 Jan 2016 10:30:15 GMT"}, http.StatusOK},
+		{"POST", map[string]string{"If-None-Match": tag}, http.StatusOK},
+	}
+
+	for i, tc := range testcases {
+		r, _ := http.NewRequest(tc.method, "/", nil)
+		for k, v := range tc.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		CondJSONResp(w, r, content, http.StatusOK, modified)
+
+		if w.Code != tc.code {
+			t.Errorf("%d: expected %d, got %d", i, tc.code, w.Code)
+		}
+		if w.Header().Get("ETag") != tag {
+			t.Errorf("%d: expected %s ETag, got %s", i, tag, w.Header().Get("ETag"))
+		}
+		if w.Header().Get("Last-Modified") != "Sat, 02 Jan 2016 10:30:15 GMT" {
+			t.Errorf("%d: invalid Last-Modified: %s", i, w.Header().Get("Last-Modified"))
+		}
+		if tc.code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%d: expected no body, got %q", i, w.Body.String())
+		}
+	}
+}
+
+func TestCheckPrecondition(t *testing.T) {
+	current := map[string]string{"name": "john doe"}
+	tag, err := ETag(current)
+	if err != nil {
+		t.Fatalf("cannot compute ETag: %s", err)
+	}
+	modified := time.Date(2016, 1, 2, 10, 30, 15, 0, time.UTC)
+
+	testcases := []struct {
+		current interface{}
+		header  map[string]string
+		ok      bool
+	}{
+		{current, nil, true},
+		{current, map[string]string{"If-Match": tag}, true},
+		{current, map[string]string{"If-Match": "W/" + tag}, false},
+		{current, map[string]string{"If-Match": `"other", ` + encodedETag(tag, "gzip")}, true},
+		{current, map[string]string{"If-Match": encodedETag(tag, "deflate")}, true},
+		{current, map[string]string{"If-Match": "*"}, true},
+		{current, map[string]string{"If-Match": `"other"`}, false},
+		{nil, map[string]string{"If-Match": "*"}, false},
+		{current, map[string]string{"If-Unmodified-Since": "Sat, 02 Jan 2016 10:30:15 GMT"}, true},
+		{current, map[string]string{"If-Unmodified-Since": "Sat, 02 Jan 2016 10:30:00 GMT"}, false},
+	}
+
+	for i, tc := range testcases {
+		r, _ := http.NewRequest("PUT", "/", nil)
+		for k, v := range tc.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		if ok := CheckPrecondition(w, r, tc.current, modified); ok != tc.ok {
+			t.Errorf("%d: expected %v, got %v", i, tc.ok, ok)
+		}
+		if tc.ok {
+			continue
+		}
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%d: expected 412, got %d", i, w.Code)
+		}
+		expected := apierr.Errors{{Type: "request_error", Code: "precondition_failed"}}
+		if errs := apierrtest.HasAPIErrors(expected, w.Body); len(errs) != 0 {
+			t.Errorf("%d: %s", i, errs)
+		}
+	}
+}
+
+func TestCheckPreconditionProblemJSON(t *testing.T) {
+	modified := time.Date(2016, 1, 2, 10, 30, 15, 0, time.UTC)
+	for i, header := range []map[string]string{
+		{"If-Match": `"other"`},
+		{"If-Unmodified-Since": "Sat, 02 Jan 2016 10:30:00 GMT"},
+	} {
+		r, _ := http.NewRequest("PUT", "/", nil)
+		r = r.WithContext(WithProblemJSON(r.Context()))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		if CheckPrecondition(w, r, map[string]string{"name": "john doe"}, modified) {
+			t.Errorf("%d: expected precondition to fail", i)
+			continue
+		}
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%d: expected 412, got %d", i, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=UTF-8" {
+			t.Errorf("%d: expected problem details, got %q content type", i, ct)
+		}
+	}
+}
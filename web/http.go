@@ -20,6 +20,8 @@ import (
 // code send as part of HTTP response.
 //
 // Even though it's possible, NEVER send array as top level structure.
+//
+// Use Respond to send content in format requested by the client.
 func JSONResp(w http.ResponseWriter, content interface{}, code int) {
 	b, err := json.MarshalIndent(content, "", "\t")
 	if err != nil {
@@ -68,6 +70,11 @@ func JSONErr(w http.ResponseWriter, errs apierr.Errors, code int) {
 // Only 4xx and 5xx are valid codes for this function. Call with any other call
 // will produce incomplete message.
 func StdJSONErr(w http.ResponseWriter, code int) {
+	JSONErr(w, apierr.Errors{}.With(stdErr(code)), code)
+}
+
+// stdErr return standard error for given status code.
+func stdErr(code int) apierr.Error {
 	err := apierr.Error{
 		Message: http.StatusText(code),
 	}
@@ -86,6 +93,5 @@ func StdJSONErr(w http.ResponseWriter, code int) {
 	case http.StatusForbidden:
 		err.Code = "forbidden"
 	}
-
-	JSONErr(w, apierr.Errors{}.With(err), code)
+	return err
 }
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+// Problem is RFC 7807 problem details document, extended with the list of
+// all errors.
+type Problem struct {
+	Type   string         `json:"type"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Errors []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError is single API error in problem details document. Instead of
+// param, JSON pointer to invalid value is provided.
+type ProblemError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Pointer string `json:"pointer,omitempty"`
+}
+
+// ProblemTypeBase is the URI prefix used to build problem type from the code
+// of the first error. If empty, all problems are of "about:blank" type.
+var ProblemTypeBase = ""
+
+// NewProblem return problem details document describing given errors.
+func NewProblem(errs apierr.Errors, code int) Problem {
+	p := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+	}
+	if ProblemTypeBase != "" && len(errs) != 0 && errs[0].Code != "" {
+		p.Type = ProblemTypeBase + errs[0].Code
+	}
+	var messages []string
+	for _, e := range errs {
+		if e.Message != "" {
+			messages = append(messages, e.Message)
+		}
+		p.Errors = append(p.Errors, ProblemError{
+			Type:    e.Type,
+			Code:    e.Code,
+			Message: e.Message,
+			Pointer: paramPointer(e.Param),
+		})
+	}
+	p.Detail = strings.Join(messages, "; ")
+	return p
+}
+
+// paramPointer return JSON pointer of the value identified by error param,
+// which is either dot separated path or already a JSON pointer.
+func paramPointer(param string) string {
+	if param == "" || strings.HasPrefix(param, "/") {
+		return param
+	}
+	return pointer(param)
+}
+
+// ProblemErr send to given writer errors as RFC 7807 problem details
+// document, with application/problem+json content type.
+func ProblemErr(w http.ResponseWriter, errs apierr.Errors, code int) {
+	b, err := json.MarshalIndent(NewProblem(errs, code), "", "\t")
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Content-Type", "application/problem+json; charset=UTF-8")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// WithProblemJSON return context that makes Err and StdErr always send
+// errors as problem details documents. Router can be configured to set it for
+// all requests it serves.
+func WithProblemJSON(ctx context.Context) context.Context {
+	return context.WithValue(ctx, "web:problem", true)
+}
+
+// Err send to given writer errors either in standard JSON format, like
+// JSONErr, or as problem details document, like ProblemErr. Problem details
+// are send if request context was created with WithProblemJSON, or if client
+// prefers application/problem+json over application/json media type.
+func Err(w http.ResponseWriter, r *http.Request, errs apierr.Errors, code int) {
+	if wantsProblem(r) {
+		ProblemErr(w, errs, code)
+	} else {
+		JSONErr(w, errs, code)
+	}
+}
+
+// StdErr send standard error for given status code, like StdJSONErr, in the
+// format selected as described for Err.
+func StdErr(w http.ResponseWriter, r *http.Request, code int) {
+	Err(w, r, apierr.Errors{}.With(stdErr(code)), code)
+}
+
+func wantsProblem(r *http.Request) bool {
+	if on, _ := r.Context().Value("web:problem").(bool); on {
+		return true
+	}
+	types, _ := acceptedTypes(r.Header.Get("Accept"))
+	for _, mediaType := range types {
+		switch mediaType {
+		case "application/problem+json":
+			return true
+		case "application/json":
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+func TestProblemErr(t *testing.T) {
+	errs := apierr.Errors{}.
+		WithRequired("amount", "amount is required").
+		WithNotString("items.0.name", "name has to be string").
+		WithNoSuchFild("/nickname", "")
+
+	w := httptest.NewRecorder()
+	ProblemErr(w, errs, http.StatusBadRequest)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=UTF-8" {
+		t.Fatalf("invalid content type: %q", ct)
+	}
+	var got Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("cannot decode response: %s", err)
+	}
+	expected := Problem{
+		Type:   "about:blank",
+		Title:  "Bad Request",
+		Status: http.StatusBadRequest,
+		Detail: "amount is required; name has to be string; unknown field",
+		Errors: []ProblemError{
+			{Type: "validation_error", Code: "required", Message: "amount is required", Pointer: "/amount"},
+			{Type: "validation_error", Code: "not_string", Message: "name has to be string", Pointer: "/items/0/name"},
+			{Type: "validation_error", Code: "no_such_field", Message: "unknown field", Pointer: "/nickname"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	defer func(base string) { ProblemTypeBase = base }(ProblemTypeBase)
+	ProblemTypeBase = "https://example.com/problems/"
+	if p := NewProblem(errs, http.StatusBadRequest); p.Type != "https://example.com/problems/required" {
+		t.Errorf("unexpected type: %q", p.Type)
+	}
+}
+
+func TestErr(t *testing.T) {
+	testcases := []struct {
+		accept  string
+		problem bool
+		ctx     bool
+	}{
+		{"", false, false},
+		{"*/*", false, false},
+		{"application/json", false, false},
+		{"application/problem+json", true, false},
+		{"application/json;q=0.5, application/problem+json", true, false},
+		{"application/json, application/problem+json", false, false},
+		{"application/problem+json;q=0.1, application/json", false, false},
+		{"application/json", true, true},
+	}
+
+	for i, tc := range testcases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		if tc.ctx {
+			r = r.WithContext(WithProblemJSON(context.Background()))
+		}
+		w := httptest.NewRecorder()
+		StdErr(w, r, http.StatusNotFound)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%d: expected 404, got %d", i, w.Code)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%d: cannot decode response: %s", i, err)
+		}
+		_, isProblem := body["status"]
+		if isProblem != tc.problem {
+			t.Errorf("%d: expected problem %v, got %s", i, tc.problem, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/log"
+)
+
+// Encoder serialize response content in a single format.
+type Encoder struct {
+	// ContentType is the value of Content-Type header of the response.
+	ContentType string
+	// Encode write serialized content to given writer.
+	Encode func(w io.Writer, content interface{}) error
+}
+
+// Encoders is a registry of response encoders, that is selecting encoder
+// using Accept header of the request.
+type Encoders struct {
+	mu       sync.RWMutex
+	types    []string
+	encoders map[string]Encoder
+}
+
+// NewEncoders return empty encoders registry.
+func NewEncoders() *Encoders {
+	return &Encoders{encoders: make(map[string]Encoder)}
+}
+
+// Register add encoder used for given media type, for example "text/csv".
+// Encoder registered first is used when request does not have Accept
+// header, or when any media type is accepted. Registering encoder for already
+// registered media type replaces it.
+func (e *Encoders) Register(mediaType string, enc Encoder) {
+	mediaType = strings.ToLower(mediaType)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.encoders[mediaType]; !ok {
+		e.types = append(e.types, mediaType)
+	}
+	e.encoders[mediaType] = enc
+}
+
+// Respond send to given writer content serialized with encoder matching
+// Accept header of the request. Media types are considered in order of their
+// quality, and then in order of declaration. Wildcards, like */* or text/*,
+// do not match media types listed explicitly, so that they can be excluded
+// with zero quality. If no registered encoder is acceptable, 406 response
+// with error message is send instead.
+//
+// If content cannot be serialized, 500 response with standard error message
+// is send. Errors are send in format selected as described for Err.
+func (e *Encoders) Respond(w http.ResponseWriter, r *http.Request, content interface{}, code int) {
+	w.Header().Add("Vary", "Accept")
+
+	enc, ok := e.negotiate(r.Header.Get("Accept"))
+	if !ok {
+		errs := apierr.Errors{}.WithNotAcceptable(
+			fmt.Sprintf("acceptable content types are %s", strings.Join(e.mediaTypes(), ", ")))
+		Err(w, r, errs, http.StatusNotAcceptable)
+		return
+	}
+
+	var b bytes.Buffer
+	if err := enc.Encode(&b, content); err != nil {
+		log.Error("cannot serialize response",
+			"content", fmt.Sprintf("%T", content),
+			"contentType", enc.ContentType,
+			"error", err.Error())
+		StdErr(w, r, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", enc.ContentType)
+	w.WriteHeader(code)
+	_, _ = b.WriteTo(w)
+}
+
+func (e *Encoders) mediaTypes() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]string(nil), e.types...)
+}
+
+// negotiate return encoder best matching given Accept header value.
+func (e *Encoders) negotiate(accept string) (Encoder, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if len(e.types) == 0 {
+		return Encoder{}, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return e.encoders[e.types[0]], true
+	}
+	types, listed := acceptedTypes(accept)
+	for _, mediaType := range types {
+		// wildcard does not match media types listed on their own, as their
+		// quality, possibly zero, is declared explicitly
+		switch {
+		case mediaType == "*/*":
+			for _, t := range e.types {
+				if !listed[t] {
+					return e.encoders[t], true
+				}
+			}
+		case strings.HasSuffix(mediaType, "/*"):
+			for _, t := range e.types {
+				if strings.HasPrefix(t, mediaType[:len(mediaType)-1]) && !listed[t] {
+					return e.encoders[t], true
+				}
+			}
+		default:
+			if enc, ok := e.encoders[mediaType]; ok {
+				return enc, true
+			}
+		}
+	}
+	return Encoder{}, false
+}
+
+// acceptedTypes return media types listed in given Accept header value,
+// ordered by quality. Media types with zero quality are not returned, but
+// all listed media types, including them, are returned as a set.
+func acceptedTypes(accept string) ([]string, map[string]bool) {
+	type accepted struct {
+		mediaType string
+		quality   float64
+	}
+	var types []accepted
+	listed := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		listed[mediaType] = true
+		if quality > 0 {
+			types = append(types, accepted{mediaType: mediaType, quality: quality})
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].quality > types[j].quality
+	})
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.mediaType
+	}
+	return names, listed
+}
+
+// DefaultEncoders is the registry used by Respond function. By default it
+// contains compact JSON (used when client does not specify), NDJSON and CSV
+// encoders.
+var DefaultEncoders = NewEncoders()
+
+func init() {
+	DefaultEncoders.Register("application/json", Encoder{
+		ContentType: "application/json; charset=UTF-8",
+		Encode:      encodeJSON,
+	})
+	DefaultEncoders.Register("application/x-ndjson", Encoder{
+		ContentType: "application/x-ndjson; charset=UTF-8",
+		Encode:      encodeNDJSON,
+	})
+	DefaultEncoders.Register("text/csv", Encoder{
+		ContentType: "text/csv; charset=UTF-8",
+		Encode:      encodeCSV,
+	})
+}
+
+// Respond send to given writer content serialized with encoder from
+// DefaultEncoders registry, selected using Accept header of the request. Use
+// JSONResp to always send JSON.
+func Respond(w http.ResponseWriter, r *http.Request, content interface{}, code int) {
+	DefaultEncoders.Respond(w, r, content, code)
+}
+
+func encodeJSON(w io.Writer, content interface{}) error {
+	return json.NewEncoder(w).Encode(content)
+}
+
+// encodeNDJSON write every element of slice content as separate line. Any
+// other content is written as single line.
+func encodeNDJSON(w io.Writer, content interface{}) error {
+	v := reflect.ValueOf(content)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return encodeJSON(w, content)
+	}
+	enc := json.NewEncoder(w)
+	for i := 0; i < v.Len(); i++ {
+		if err := enc.Encode(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeCSV write content that is either list of rows ([][]string) or slice
+// of structures. In the later case, header row with JSON names of exported
+// fields is written first.
+func encodeCSV(w io.Writer, content interface{}) error {
+	cw := csv.NewWriter(w)
+	if rows, ok := content.([][]string); ok {
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	v := reflect.ValueOf(content)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("cannot serialize %T as CSV", content)
+	}
+	t := v.Type().Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot serialize %T as CSV", content)
+	}
+
+	var (
+		fields []int
+		header []string
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue
+		}
+		fields = append(fields, i)
+		header = append(header, fieldName(f))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := make([]string, len(fields))
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+			elem = elem.Elem()
+		}
+		for j, field := range fields {
+			row[j] = ""
+			if elem.Kind() == reflect.Struct {
+				row[j] = csvValue(elem.Field(field))
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
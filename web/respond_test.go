@@ -0,0 +1,120 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/apierr/apierrtest"
+)
+
+func TestRespond(t *testing.T) {
+	type row struct {
+		ID     int     `json:"id"`
+		Name   string  `json:"name"`
+		Amount *string `json:"amount,omitempty"`
+		Secret string  `json:"-"`
+	}
+	amount := "1.50"
+	content := []row{{ID: 1, Name: "a, b", Amount: &amount, Secret: "x"}, {ID: 2, Name: "c"}}
+
+	testcases := []struct {
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusOK, "application/json; charset=UTF-8",
+			`[{"id":1,"name":"a, b","amount":"1.50"},{"id":2,"name":"c"}]` + "\n"},
+		{"*/*", http.StatusOK, "application/json; charset=UTF-8",
+			`[{"id":1,"name":"a, b","amount":"1.50"},{"id":2,"name":"c"}]` + "\n"},
+		{"application/x-ndjson", http.StatusOK, "application/x-ndjson; charset=UTF-8",
+			`{"id":1,"name":"a, b","amount":"1.50"}` + "\n" + `{"id":2,"name":"c"}` + "\n"},
+		{"text/*", http.StatusOK, "text/csv; charset=UTF-8",
+			"id,name,amount\n1,\"a, b\",1.50\n2,c,\n"},
+		{"application/json;q=0.5, text/csv", http.StatusOK, "text/csv; charset=UTF-8",
+			"id,name,amount\n1,\"a, b\",1.50\n2,c,\n"},
+		{"text/html, application/*;q=0.1", http.StatusOK, "application/json; charset=UTF-8",
+			`[{"id":1,"name":"a, b","amount":"1.50"},{"id":2,"name":"c"}]` + "\n"},
+		{"text/html", http.StatusNotAcceptable, "application/json; charset=UTF-8", ""},
+		{"application/json;q=0", http.StatusNotAcceptable, "application/json; charset=UTF-8", ""},
+		{"application/json;q=0, */*", http.StatusOK, "application/x-ndjson; charset=UTF-8",
+			`{"id":1,"name":"a, b","amount":"1.50"}` + "\n" + `{"id":2,"name":"c"}` + "\n"},
+		{"application/json;q=0.1, application/*", http.StatusOK, "application/x-ndjson; charset=UTF-8",
+			`{"id":1,"name":"a, b","amount":"1.50"}` + "\n" + `{"id":2,"name":"c"}` + "\n"},
+		{"text/csv;q=0, text/*", http.StatusNotAcceptable, "application/json; charset=UTF-8", ""},
+	}
+
+	for i, tc := range testcases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		Respond(w, r, content, http.StatusOK)
+
+		if w.Code != tc.code {
+			t.Errorf("%d: expected %d, got %d", i, tc.code, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != tc.contentType {
+			t.Errorf("%d: expected content type %q, got %q", i, tc.contentType, ct)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%d: expected Vary header, got %q", i, w.Header().Get("Vary"))
+		}
+		if tc.code == http.StatusNotAcceptable {
+			if errs := apierrtest.HasAPIErrors([]apierr.Error{{Type: "request_error", Code: "not_acceptable"}}, w.Body); len(errs) != 0 {
+				t.Errorf("%d: %s", i, errs)
+			}
+			continue
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("%d: expected %q, got %q", i, tc.body, w.Body.String())
+		}
+	}
+}
+
+func TestRespondProblemJSON(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r = r.WithContext(WithProblemJSON(r.Context()))
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	Respond(w, r, []int{1}, http.StatusOK)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=UTF-8" {
+		t.Errorf("expected problem details, got %q content type", ct)
+	}
+}
+
+func TestEncodersRegister(t *testing.T) {
+	encoders := NewEncoders()
+	encoders.Register("text/plain", Encoder{
+		ContentType: "text/plain",
+		Encode: func(w io.Writer, content interface{}) error {
+			_, err := io.WriteString(w, "plain")
+			return err
+		},
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "TEXT/plain")
+	w := httptest.NewRecorder()
+	encoders.Respond(w, r, nil, http.StatusCreated)
+	if w.Code != http.StatusCreated || !bytes.Equal(w.Body.Bytes(), []byte("plain")) {
+		t.Errorf("expected 201 plain, got %d %q", w.Code, w.Body.String())
+	}
+
+	// not serializable content
+	r.Header.Set("Accept", "text/csv")
+	w = httptest.NewRecorder()
+	Respond(w, r, map[string]int{"a": 1}, http.StatusOK)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,248 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/optiopay/x/apierr"
+	"github.com/optiopay/x/log"
+)
+
+// Iterator provides elements of the streamed collection. It's implemented
+// by sql.Rows like structures:
+//
+//	for it.Next() {
+//		elem := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type Iterator interface {
+	// Next prepare next element and return true if there is one.
+	Next() bool
+	// Value return current element.
+	Value() interface{}
+	// Err return error that stopped iteration, if any.
+	Err() error
+}
+
+// SliceIterator return iterator over elements of given slice.
+func SliceIterator(slice interface{}) Iterator {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		panic(fmt.Sprintf("cannot iterate over %T", slice))
+	}
+	return &sliceIterator{v: v, pos: -1}
+}
+
+type sliceIterator struct {
+	v   reflect.Value
+	pos int
+}
+
+func (it *sliceIterator) Next() bool {
+	it.pos++
+	return it.pos < it.v.Len()
+}
+
+func (it *sliceIterator) Value() interface{} {
+	return it.v.Index(it.pos).Interface()
+}
+
+func (it *sliceIterator) Err() error {
+	return nil
+}
+
+// StreamFlushEvery is the number of elements written by streaming functions
+// before buffered data is flushed to the client.
+var StreamFlushEvery = 100
+
+// StreamJSON send to given writer JSON object with single field, which is a
+// list of all elements returned by the iterator:
+//
+//	{"transactions": [{...}, {...}]}
+//
+// Elements are serialized one by one, so that the whole collection is never
+// held in memory. If iterator fails before the first element is written,
+// error response is send instead, using Err. If iterator fails later,
+// response is completed with "errors" field describing the failure. Error of
+// apierr.Error type is send as it is, any other error is send as generic
+// server error.
+//
+// Streaming stops as soon as the response cannot be written or the request
+// context is done, for example because client disconnected. Returned is the
+// error that stopped streaming, if any.
+func StreamJSON(w http.ResponseWriter, r *http.Request, field string, it Iterator, code int) error {
+	key, err := json.Marshal(field)
+	if err != nil {
+		return err
+	}
+	s := newStream(w, r, "application/json; charset=UTF-8", code)
+	s.open = "{" + string(key) + ":["
+	err = s.run(it, func(i int, b []byte) {
+		if i != 0 {
+			s.buf.WriteString(",")
+		}
+		s.buf.Write(b)
+	})
+	if !s.started {
+		return s.finish(err)
+	}
+	s.buf.WriteString("]")
+	if err != nil {
+		s.buf.WriteString(`,"errors":`)
+		s.writeErrors(err)
+	}
+	s.buf.WriteString("}\n")
+	return s.finish(err)
+}
+
+// StreamNDJSON send to given writer all elements returned by the iterator,
+// every serialized as JSON in separate line. If iterator fails before the
+// first element is written, error response is send instead, using Err.
+// If iterator fails later, the last line is JSON object with "errors" field
+// describing the failure. Like StreamJSON, it stops when the response cannot
+// be written or the request context is done.
+func StreamNDJSON(w http.ResponseWriter, r *http.Request, it Iterator, code int) error {
+	s := newStream(w, r, "application/x-ndjson; charset=UTF-8", code)
+	err := s.run(it, func(i int, b []byte) {
+		s.buf.Write(b)
+		s.buf.WriteString("\n")
+	})
+	if s.started && err != nil {
+		s.buf.WriteString(`{"errors":`)
+		s.writeErrors(err)
+		s.buf.WriteString("}\n")
+	}
+	return s.finish(err)
+}
+
+type stream struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	out         *errorWriter
+	buf         *bufio.Writer
+	contentType string
+	code        int
+	started     bool
+	// open is written before the first element
+	open string
+}
+
+func newStream(w http.ResponseWriter, r *http.Request, contentType string, code int) *stream {
+	out := &errorWriter{w: w}
+	return &stream{
+		w:           w,
+		r:           r,
+		out:         out,
+		buf:         bufio.NewWriter(out),
+		contentType: contentType,
+		code:        code,
+	}
+}
+
+// errorWriter keeps the first error returned by the wrapped writer.
+type errorWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errorWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// run serialize all elements of the iterator and pass them to write function.
+// Data is flushed to the client every StreamFlushEvery elements. Iteration
+// stops if data cannot be written or request context is done.
+func (s *stream) run(it Iterator, write func(i int, b []byte)) error {
+	for i := 0; ; i++ {
+		if s.out.err != nil {
+			return s.out.err
+		}
+		if err := s.r.Context().Err(); err != nil {
+			return err
+		}
+		if !it.Next() {
+			break
+		}
+		b, err := json.Marshal(it.Value())
+		if err != nil {
+			return err
+		}
+		if !s.started {
+			s.start()
+		}
+		write(i, b)
+		if StreamFlushEvery > 0 && (i+1)%StreamFlushEvery == 0 {
+			s.flush()
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if !s.started {
+		// empty collection is still a valid response
+		s.start()
+	}
+	return nil
+}
+
+func (s *stream) start() {
+	s.started = true
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.WriteHeader(s.code)
+	s.buf.WriteString(s.open)
+}
+
+func (s *stream) flush() {
+	if err := s.buf.Flush(); err != nil {
+		return
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *stream) writeErrors(err error) {
+	b, merr := json.Marshal(streamErrors(err))
+	if merr != nil {
+		panic(merr)
+	}
+	s.buf.Write(b)
+}
+
+// finish flush remaining data or, if nothing was written yet, send error
+// response.
+func (s *stream) finish(err error) error {
+	if err != nil {
+		log.Error("cannot stream response", "error", err.Error())
+	}
+	if !s.started {
+		errs := streamErrors(err)
+		Err(s.w, s.r, errs, ErrStatus(errs))
+		return err
+	}
+	s.flush()
+	return err
+}
+
+// streamErrors return API errors describing given streaming failure.
+func streamErrors(err error) apierr.Errors {
+	if e, ok := err.(apierr.Error); ok {
+		return apierr.Errors{e}
+	}
+	return apierr.Errors{
+		{
+			Type:    "server_error",
+			Message: http.StatusText(http.StatusInternalServerError),
+		},
+	}
+}
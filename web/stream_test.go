@@ -0,0 +1,182 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/optiopay/x/apierr"
+	"golang.org/x/net/context"
+)
+
+// failingIterator return given number of elements and then fails.
+type failingIterator struct {
+	n   int
+	err error
+}
+
+func (it *failingIterator) Next() bool {
+	if it.n == 0 {
+		return false
+	}
+	it.n--
+	return true
+}
+
+func (it *failingIterator) Value() interface{} {
+	return map[string]int{"n": it.n}
+}
+
+func (it *failingIterator) Err() error {
+	return it.err
+}
+
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes++
+}
+
+func TestStreamJSON(t *testing.T) {
+	testcases := []struct {
+		it   Iterator
+		code int
+		body string
+		err  bool
+	}{
+		{SliceIterator([]int{1, 2, 3}), http.StatusOK, `{"items":[1,2,3]}` + "\n", false},
+		{SliceIterator([]int{}), http.StatusOK, `{"items":[]}` + "\n", false},
+		{
+			&failingIterator{n: 2, err: errors.New("connection lost")},
+			http.StatusOK,
+			`{"items":[{"n":1},{"n":0}],"errors":[{"type":"server_error","message":"Internal Server Error"}]}` + "\n",
+			true,
+		},
+		{
+			&failingIterator{n: 1, err: apierr.Error{Type: "request_error", Code: "forbidden", Message: "no access"}},
+			http.StatusOK,
+			`{"items":[{"n":0}],"errors":[{"type":"request_error","code":"forbidden","message":"no access"}]}` + "\n",
+			true,
+		},
+		{SliceIterator([]interface{}{1, make(chan int)}), http.StatusOK,
+			`{"items":[1],"errors":[{"type":"server_error","message":"Internal Server Error"}]}` + "\n", true},
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	for i, tc := range testcases {
+		w := httptest.NewRecorder()
+		err := StreamJSON(w, r, "items", tc.it, http.StatusOK)
+		if (err != nil) != tc.err {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+		if w.Code != tc.code {
+			t.Errorf("%d: expected %d, got %d", i, tc.code, w.Code)
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("%d: expected %s, got %s", i, tc.body, w.Body.String())
+		}
+		var v interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+			t.Errorf("%d: invalid JSON: %s", i, err)
+		}
+	}
+}
+
+func TestStreamFailsBeforeFirstElement(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	err := StreamJSON(w, r, "items", &failingIterator{err: errors.New("boom")}, http.StatusOK)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	var resp struct {
+		Errors apierr.Errors `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Errors) != 1 {
+		t.Fatalf("expected error response, got %s", w.Body.String())
+	}
+
+	r = r.WithContext(WithProblemJSON(r.Context()))
+	w = httptest.NewRecorder()
+	forbidden := apierr.Error{Type: "request_error", Code: "forbidden", Message: "no access"}
+	if err := StreamNDJSON(w, r, &failingIterator{err: forbidden}, http.StatusOK); err == nil {
+		t.Fatal("expected error")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json; charset=UTF-8" {
+		t.Errorf("expected problem details, got %q content type", ct)
+	}
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	defer func(n int) { StreamFlushEvery = n }(StreamFlushEvery)
+	StreamFlushEvery = 2
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := StreamNDJSON(w, r, SliceIterator([]string{"a", "b", "c", "d", "e"}), http.StatusOK); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "\"a\"\n\"b\"\n\"c\"\n\"d\"\n\"e\"\n"; w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/x-ndjson; charset=UTF-8" {
+		t.Errorf("invalid content type: %q", w.Header().Get("Content-Type"))
+	}
+	// two periodic flushes and the final one
+	if w.flushes != 3 {
+		t.Errorf("expected 3 flushes, got %d", w.flushes)
+	}
+
+	w = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := StreamNDJSON(w, r, &failingIterator{n: 1, err: errors.New("boom")}, http.StatusOK); err == nil {
+		t.Fatal("expected error")
+	}
+	if want := `{"n":0}` + "\n" + `{"errors":[{"type":"server_error","message":"Internal Server Error"}]}` + "\n"; w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+// brokenWriter fails all writes, like connection closed by the client.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestStreamStops(t *testing.T) {
+	defer func(n int) { StreamFlushEvery = n }(StreamFlushEvery)
+	StreamFlushEvery = 2
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	it := &failingIterator{n: 1000}
+	if err := StreamNDJSON(brokenWriter{httptest.NewRecorder()}, r, it, http.StatusOK); err == nil {
+		t.Error("expected write error")
+	}
+	if it.n != 998 {
+		t.Errorf("expected iteration to stop after the first flush, %d elements left", it.n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it = &failingIterator{n: 1000}
+	err := StreamJSON(httptest.NewRecorder(), r.WithContext(ctx), "items", it, http.StatusOK)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if it.n != 1000 {
+		t.Errorf("expected no iteration, %d elements left", it.n)
+	}
+}
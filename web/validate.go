@@ -0,0 +1,237 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/optiopay/x/apierr"
+)
+
+// Validate check given structure using rules defined by "validate" tag of
+// its fields and return errors of all invalid fields. Nested structures,
+// pointers and slices are validated as well. Param of the error is the dot
+// separated path of the field, using JSON names, with slice elements
+// identified by index, for example "items.0.amount".
+//
+// Rules are coma separated and checked in declared order, until the first
+// failing rule:
+//
+//	required         value must not be zero value (or nil pointer)
+//	gt=N, gte=N      value must be greater (or equal) than N
+//	lt=N, lte=N      value must be lower (or equal) than N
+//	range=N..M       value must be between N and M, inclusive
+//	choice=A|B       value must be one of given choices
+//	money            value must be amount with at most two decimal places
+//	currency         value must be three letters, upper case currency code
+//
+// Numbers are compared by value, strings, slices and maps by their length.
+// Rules other than required are not checked for absent values, that are nil
+// pointers, slices and maps, and empty strings. Numbers are never absent, so
+// optional number fields have to be declared as pointers:
+//
+//	type Payment struct {
+//		Amount   string `json:"amount" validate:"required,money"`
+//		Currency string `json:"currency" validate:"required,choice=EUR|USD"`
+//		Items    []Item `json:"items" validate:"lte=100"`
+//		Discount *int   `json:"discount" validate:"range=1..50"`
+//	}
+//
+// Invalid rule definition is a programming error and causes panic.
+func Validate(v interface{}) apierr.Errors {
+	var errs apierr.Errors
+	walk(&errs, reflect.ValueOf(v), "")
+	return errs
+}
+
+// walk validate all fields of given value and its children.
+func walk(errs *apierr.Errors, v reflect.Value, path string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				// unexported
+				continue
+			}
+			param := joinPath(path, fieldName(f))
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if !check(errs, v.Field(i), param, tag) {
+					continue
+				}
+			}
+			walk(errs, v.Field(i), param)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walk(errs, v.Index(i), joinPath(path, strconv.Itoa(i)))
+		}
+	}
+}
+
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+var (
+	moneyRx    = regexp.MustCompile(`^-?\d+(\.\d{1,2})?$`)
+	currencyRx = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// check validate value against given rules, adding error if any of rules is
+// not satisfied. Returns false if value is invalid.
+func check(errs *apierr.Errors, v reflect.Value, param, rules string) bool {
+	missing := v.IsZero()
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	absent := false
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		absent = v.IsNil()
+	case reflect.String:
+		absent = v.Len() == 0
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		if name == "required" {
+			if missing {
+				*errs = errs.WithRequired(param, fmt.Sprintf("%q is required", param))
+				return false
+			}
+			continue
+		}
+		if absent {
+			return true
+		}
+
+		switch name {
+		case "gt":
+			if !(measure(v, param) > number(arg, rule)) {
+				*errs = errs.WithNotGt(param, fmt.Sprintf("%q has to be greater than %s", param, arg))
+				return false
+			}
+		case "gte":
+			if !(measure(v, param) >= number(arg, rule)) {
+				*errs = errs.WithNotGte(param, fmt.Sprintf("%q has to be greater or equal to %s", param, arg))
+				return false
+			}
+		case "lt":
+			if !(measure(v, param) < number(arg, rule)) {
+				*errs = errs.WithNotLt(param, fmt.Sprintf("%q has to be lower than %s", param, arg))
+				return false
+			}
+		case "lte":
+			if !(measure(v, param) <= number(arg, rule)) {
+				*errs = errs.WithNotLte(param, fmt.Sprintf("%q has to be lower or equal to %s", param, arg))
+				return false
+			}
+		case "range":
+			bounds := strings.SplitN(arg, "..", 2)
+			if len(bounds) != 2 {
+				panic(fmt.Sprintf("invalid validation rule %q", rule))
+			}
+			if n := measure(v, param); n < number(bounds[0], rule) || n > number(bounds[1], rule) {
+				*errs = errs.WithNotInRange(param, fmt.Sprintf("%q has to be between %s and %s", param, bounds[0], bounds[1]))
+				return false
+			}
+		case "choice":
+			if !isChoice(fmt.Sprint(v.Interface()), strings.Split(arg, "|")) {
+				*errs = errs.WithInvalidChoice(param, fmt.Sprintf("%q has to be one of %s", param, strings.Replace(arg, "|", ", ", -1)))
+				return false
+			}
+		case "money":
+			if !isMoney(v) {
+				*errs = errs.WithNotMoney(param, fmt.Sprintf("%q has to be amount with at most two decimal places", param))
+				return false
+			}
+		case "currency":
+			if v.Kind() != reflect.String || !currencyRx.MatchString(v.String()) {
+				*errs = errs.WithNotCurrency(param, fmt.Sprintf("%q has to be currency code", param))
+				return false
+			}
+		default:
+			panic(fmt.Sprintf("unknown validation rule %q", rule))
+		}
+	}
+	return true
+}
+
+// measure return value of a number or length of string, slice or map.
+func measure(v reflect.Value, param string) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len())
+	default:
+		panic(fmt.Sprintf("cannot compare %q of type %s", param, v.Type()))
+	}
+}
+
+func number(s, rule string) float64 {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid validation rule %q: %s", rule, err))
+	}
+	return n
+}
+
+func isChoice(s string, choices []string) bool {
+	for _, c := range choices {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+func isMoney(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return moneyRx.MatchString(v.String())
+	case reflect.Float32, reflect.Float64:
+		return moneyRx.MatchString(strconv.FormatFloat(v.Float(), 'f', -1, 64))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/optiopay/x/apierr"
+)
+
+func TestValidate(t *testing.T) {
+	type item struct {
+		Name     string  `json:"name" validate:"required,lte=5"`
+		Quantity int     `json:"quantity" validate:"gt=0"`
+		Price    float64 `json:"price" validate:"money"`
+	}
+	type payment struct {
+		Amount   string  `json:"amount" validate:"required,money"`
+		Currency string  `json:"currency" validate:"required,currency,choice=EUR|USD"`
+		Fee      *int    `json:"fee,omitempty" validate:"required,range=0..100"`
+		Items    []item  `json:"items" validate:"required,lte=2"`
+		Buyer    *item   `json:"buyer"`
+		Note     string  `validate:"lt=4"`
+		Discount float64 `json:"discount" validate:"gte=0"`
+		internal string
+	}
+
+	zero, fee := 0, 150
+	testcases := []struct {
+		input interface{}
+		errs  apierr.Errors
+	}{
+		{
+			payment{Amount: "12.50", Currency: "EUR", Fee: &zero, Items: []item{{Name: "a", Quantity: 1, Price: 1.25}}},
+			nil,
+		},
+		{
+			&payment{},
+			apierr.Errors{}.
+				WithRequired("amount", "").
+				WithRequired("currency", "").
+				WithRequired("fee", "").
+				WithRequired("items", ""),
+		},
+		{
+			payment{
+				Amount:   "12.505",
+				Currency: "eur",
+				Fee:      &fee,
+				Items:    []item{{Name: "a", Quantity: 1}, {Name: "toolong", Price: 1.001}},
+				Buyer:    &item{},
+				Note:     "long",
+				Discount: -1,
+			},
+			apierr.Errors{}.
+				WithNotMoney("amount", "").
+				WithNotCurrency("currency", "").
+				WithNotInRange("fee", "").
+				WithNotLte("items.1.name", "").
+				WithNotGt("items.1.quantity", "").
+				WithNotMoney("items.1.price", "").
+				WithRequired("buyer.name", "").
+				WithNotGt("buyer.quantity", "").
+				WithNotLt("Note", "").
+				WithNotGte("discount", ""),
+		},
+		{
+			payment{Amount: "1", Currency: "PLN", Fee: &zero, Items: make([]item, 3)},
+			apierr.Errors{}.
+				WithInvalidChoice("currency", "").
+				WithNotLte("items", ""),
+		},
+		{
+			[]item{{Name: "a", Quantity: -1}},
+			apierr.Errors{}.WithNotGt("0.quantity", ""),
+		},
+	}
+
+	for i, tc := range testcases {
+		errs := Validate(tc.input)
+		for j := range errs {
+			errs[j].Message = ""
+		}
+		if !reflect.DeepEqual(errs, tc.errs) {
+			t.Errorf("%d: expected %v, got %v", i, tc.errs, errs)
+		}
+	}
+}
+
+func TestValidateInvalidRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	Validate(struct {
+		A int `validate:"gt=x"`
+	}{A: 1})
+}